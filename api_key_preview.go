@@ -0,0 +1,127 @@
+package mongodbatlas
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/mongodb/go-client-mongodb-atlas/mongodbatlas"
+)
+
+// programmaticAPIKeyPreview resolves the MongoDB Atlas calls that
+// programmaticAPIKeyCreate would make for a credential, and checks that the
+// configured key is allowed to make them, without changing anything in
+// MongoDB Atlas.
+func (b *Backend) programmaticAPIKeyPreview(ctx context.Context, req *logical.Request, displayName string, cred *atlasCredentialEntry) (*logical.Response, error) {
+	s := req.Storage
+
+	mountID, err := b.getMountID(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	apiKeyDescription, err := genAPIKeyDescription(displayName, mountID)
+	if err != nil {
+		return nil, errwrap.Wrapf("error generating description: {{err}}", err)
+	}
+	client, err := b.connectionClient(ctx, s, cred.Connection)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	organizationID := cred.OrganizationID
+	if isProjectKey(cred.OrganizationID, cred.ProjectID) {
+		organizationID, err = projectOrganizationID(ctx, client, cred.ProjectID)
+		if err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+	}
+
+	var accessList []string
+	accessList = append(accessList, cred.CIDRBlocks...)
+	accessList = append(accessList, cred.IPAddresses...)
+
+	var keyType string
+	var calls []string
+	switch {
+	case isOrgKey(cred.OrganizationID, cred.ProjectID):
+		keyType = orgProgrammaticAPIKey
+		calls = append(calls, fmt.Sprintf("POST orgs/%s/apiKeys", organizationID))
+	case isProjectKey(cred.OrganizationID, cred.ProjectID):
+		keyType = projectProgrammaticAPIKey
+		calls = append(calls, fmt.Sprintf("POST groups/%s/apiKeys", cred.ProjectID))
+	case isAssignedToProject(cred.OrganizationID, cred.ProjectID):
+		keyType = "organization_assigned_to_project"
+		calls = append(calls, fmt.Sprintf("POST orgs/%s/apiKeys", organizationID))
+	}
+	if len(accessList) > 0 {
+		calls = append(calls, fmt.Sprintf("POST orgs/%s/apiKeys/<programmatic_api_key_id>/whitelist", organizationID))
+	}
+	if isAssignedToProject(cred.OrganizationID, cred.ProjectID) {
+		calls = append(calls, fmt.Sprintf("PATCH groups/%s/apiKeys/<programmatic_api_key_id>", cred.ProjectID))
+	}
+
+	defaultLease, maxLease, err := b.getCredentialLease(ctx, s, cred)
+	if err != nil {
+		return nil, err
+	}
+
+	rootKey, err := getRootAPIKey(ctx, client)
+	if err != nil {
+		return nil, errwrap.Wrapf("error reading the configured programmatic API key: {{err}}", err)
+	}
+	missing := missingRootRoles(rootKey, organizationID, cred.ProjectID, keyType, len(accessList) > 0)
+
+	resp := &logical.Response{
+		Data: map[string]interface{}{
+			"key_type":        keyType,
+			"organization_id": organizationID,
+			"project_id":      cred.ProjectID,
+			"roles":           cred.Roles,
+			"project_roles":   cred.ProjectRoles,
+			"access_list":     accessList,
+			"description":     apiKeyDescription,
+			"ttl":             int64(defaultLease.Seconds()),
+			"max_ttl":         int64(maxLease.Seconds()),
+			"calls":           calls,
+			"root_allowed":    len(missing) == 0,
+		},
+	}
+	resp.AddWarning("dry run: no programmatic API key was created, and the description of the key will have a different random suffix")
+	for _, message := range missing {
+		resp.AddWarning(message)
+	}
+
+	return resp, nil
+}
+
+// missingRootRoles describes the roles the configured key lacks to create a
+// key of the given type. Creating organization keys and managing whitelists
+// requires ORG_OWNER, and creating project keys either ORG_OWNER or
+// GROUP_OWNER in the project.
+func missingRootRoles(rootKey *mongodbatlas.APIKey, orgID, projectID, keyType string, accessList bool) []string {
+	var orgOwner, projectOwner bool
+	for _, role := range rootKey.Roles {
+		switch {
+		case role.OrgID == orgID && role.RoleName == "ORG_OWNER":
+			orgOwner = true
+		case role.GroupID == projectID && role.RoleName == "GROUP_OWNER":
+			projectOwner = true
+		}
+	}
+	if orgOwner {
+		return nil
+	}
+
+	var missing []string
+	switch {
+	case keyType != projectProgrammaticAPIKey:
+		missing = append(missing, fmt.Sprintf("the configured programmatic API key needs ORG_OWNER in organization %q to create organization keys", orgID))
+	case !projectOwner:
+		missing = append(missing, fmt.Sprintf("the configured programmatic API key needs ORG_OWNER in organization %q or GROUP_OWNER in project %q to create project keys", orgID, projectID))
+	}
+	if accessList {
+		missing = append(missing, fmt.Sprintf("the configured programmatic API key needs ORG_OWNER in organization %q to add whitelist entries", orgID))
+	}
+	return missing
+}
@@ -0,0 +1,191 @@
+package mongodbatlas
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/mongodb/go-client-mongodb-atlas/mongodbatlas"
+)
+
+// apiKeyTemplate holds the settings of an existing programmatic API key that
+// are needed to create an identical one.
+type apiKeyTemplate struct {
+	OrganizationID string
+	Description    string
+	Roles          []string
+	ProjectRoles   map[string][]string
+	AccessList     []*mongodbatlas.WhitelistAPIKeysReq
+}
+
+// getAPIKeyTemplate reads the roles, project assignments and access list of an
+// existing programmatic API key.
+func getAPIKeyTemplate(ctx context.Context, client *mongodbatlas.Client, orgID, keyID string) (*apiKeyTemplate, error) {
+	key, _, err := client.APIKeys.Get(ctx, orgID, keyID)
+	if err != nil {
+		return nil, errwrap.Wrapf("error reading programmatic API key: {{err}}", err)
+	}
+
+	template := &apiKeyTemplate{
+		OrganizationID: orgID,
+		Description:    key.Desc,
+		ProjectRoles:   map[string][]string{},
+	}
+
+	for _, role := range key.Roles {
+		switch {
+		case role.GroupID != "":
+			template.ProjectRoles[role.GroupID] = append(template.ProjectRoles[role.GroupID], role.RoleName)
+		default:
+			template.Roles = append(template.Roles, role.RoleName)
+		}
+	}
+
+	if len(template.Roles) == 0 {
+		return nil, errors.New("programmatic API key has no organization roles")
+	}
+
+	whitelist, _, err := client.WhitelistAPIKeys.List(ctx, orgID, keyID)
+	if err != nil {
+		return nil, errwrap.Wrapf("error reading programmatic API key access list: {{err}}", err)
+	}
+
+	for _, entry := range whitelist.Results {
+		// Single IP address entries are also reported with a /32 CIDR block
+		if entry.CidrBlock != "" {
+			template.AccessList = append(template.AccessList, &mongodbatlas.WhitelistAPIKeysReq{
+				CidrBlock: entry.CidrBlock,
+			})
+			continue
+		}
+		template.AccessList = append(template.AccessList, &mongodbatlas.WhitelistAPIKeysReq{
+			IPAddress: entry.IPAddress,
+		})
+	}
+
+	return template, nil
+}
+
+// createAPIKeyFromTemplate creates a new programmatic API key with the
+// organization roles of the template. The project assignments and the access
+// list are applied afterwards with applyAPIKeyTemplate, which allows callers
+// to record the new key before doing so.
+func createAPIKeyFromTemplate(ctx context.Context, client *mongodbatlas.Client, template *apiKeyTemplate) (*mongodbatlas.APIKey, error) {
+	key, _, err := client.APIKeys.Create(ctx, template.OrganizationID, &mongodbatlas.APIKeyInput{
+		Desc:  template.Description,
+		Roles: template.Roles,
+	})
+	if err != nil {
+		return nil, errwrap.Wrapf("error creating programmatic API key: {{err}}", err)
+	}
+
+	return key, nil
+}
+
+// applyAPIKeyTemplate assigns the key to the projects of the template and
+// adds the template access list entries to it.
+func applyAPIKeyTemplate(ctx context.Context, client *mongodbatlas.Client, template *apiKeyTemplate, keyID string) error {
+	for projectID, roles := range template.ProjectRoles {
+		if _, err := client.ProjectAPIKeys.Assign(ctx, projectID, keyID, &mongodbatlas.AssignAPIKey{
+			Roles: roles,
+		}); err != nil {
+			return errwrap.Wrapf("error assigning programmatic API key to project: {{err}}", err)
+		}
+	}
+
+	if len(template.AccessList) > 0 {
+		if _, _, err := client.WhitelistAPIKeys.Create(ctx, template.OrganizationID, keyID, template.AccessList); err != nil {
+			return errwrap.Wrapf("error adding programmatic API key access list entries: {{err}}", err)
+		}
+	}
+
+	return nil
+}
+
+// deleteAPIKey deletes an organization programmatic API key, a key that is
+// already gone is not considered an error.
+func deleteAPIKey(ctx context.Context, client *mongodbatlas.Client, orgID, keyID string) error {
+	res, err := client.APIKeys.Delete(ctx, orgID, keyID)
+	if err != nil {
+		if res != nil && res.StatusCode == http.StatusNotFound {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// projectOrganizationID returns the organization a project belongs to, which
+// is also where the programmatic API keys created for the project live.
+func projectOrganizationID(ctx context.Context, client *mongodbatlas.Client, projectID string) (string, error) {
+	project, _, err := client.Projects.GetOneProject(ctx, projectID)
+	if err != nil {
+		return "", errwrap.Wrapf(fmt.Sprintf("error reading project %q: {{err}}", projectID), err)
+	}
+	return project.OrgID, nil
+}
+
+// listPageSize is the number of items requested per page from the list
+// endpoints of the MongoDB Atlas API.
+const listPageSize = 100
+
+// listAPIKeys pages through all the programmatic API keys of an organization.
+func listAPIKeys(ctx context.Context, client *mongodbatlas.Client, orgID string) ([]mongodbatlas.APIKey, error) {
+	var keys []mongodbatlas.APIKey
+	options := &mongodbatlas.ListOptions{
+		PageNum:      1,
+		ItemsPerPage: listPageSize,
+	}
+	for {
+		page, res, err := client.APIKeys.List(ctx, orgID, options)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, page...)
+
+		if len(page) == 0 || res.IsLastPage() {
+			return keys, nil
+		}
+		options.PageNum++
+	}
+}
+
+// findAPIKeyByDescription returns the programmatic API key of an organization
+// with the given description, or nil if there is none.
+func findAPIKeyByDescription(ctx context.Context, client *mongodbatlas.Client, orgID, description string) (*mongodbatlas.APIKey, error) {
+	keys, err := listAPIKeys(ctx, client, orgID)
+	if err != nil {
+		return nil, errwrap.Wrapf("error listing programmatic API keys: {{err}}", err)
+	}
+
+	for i := range keys {
+		if keys[i].Desc == description {
+			return &keys[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// listProjectAPIKeys pages through all the programmatic API keys with access
+// to a project.
+func listProjectAPIKeys(ctx context.Context, client *mongodbatlas.Client, projectID string) ([]mongodbatlas.APIKey, error) {
+	var keys []mongodbatlas.APIKey
+	options := &mongodbatlas.ListOptions{
+		PageNum:      1,
+		ItemsPerPage: listPageSize,
+	}
+	for {
+		page, res, err := client.ProjectAPIKeys.List(ctx, projectID, options)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, page...)
+
+		if len(page) == 0 || res.IsLastPage() {
+			return keys, nil
+		}
+		options.PageNum++
+	}
+}
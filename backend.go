@@ -2,11 +2,14 @@ package mongodbatlas
 
 import (
 	"context"
+	"fmt"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/hashicorp/go-multierror"
 	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/consts"
 	"github.com/hashicorp/vault/sdk/logical"
 	"github.com/mongodb/go-client-mongodb-atlas/mongodbatlas"
 )
@@ -30,35 +33,125 @@ func NewBackend(system logical.SystemView) *Backend {
 			},
 			SealWrapStorage: []string{
 				"config",
+				"config/lease",
+				connectionPath,
+				staticRolePath,
 			},
 		},
 
+		// The paths under a role come first, as role names may contain
+		// slashes
 		Paths: []*framework.Path{
+			b.pathRoleRevokeAll(),
 			b.pathRolesList(),
 			b.pathRoles(),
 			b.pathConfig(),
+			b.pathConfigLease(),
+			b.pathConfigRotateRoot(),
+			b.pathConnectionsList(),
+			b.pathConnections(),
+			b.pathCredentialsAccessList(),
 			b.pathCredentials(),
+			b.pathStaticRolesList(),
+			b.pathStaticRoles(),
+			b.pathStaticCredentials(),
+			b.pathRotateRole(),
+			b.pathTidyKeys(),
+			b.pathKeysList(),
+			b.pathKeys(),
+			b.pathLookupPublicKey(),
+			b.pathRevokePublicKey(),
+			b.pathOrganizationRevokeAll(),
+			b.pathProjectRevokeAll(),
 		},
 
 		Secrets: []*framework.Secret{
 			b.programmaticAPIKeys(),
+			b.databaseUsers(),
 		},
 
-		WALRollback:       b.pathProgrammaticAPIKeyRollback,
+		PeriodicFunc:      b.periodicFunc,
+		Invalidate:        b.invalidate,
+		WALRollback:       b.walRollback,
 		WALRollbackMinAge: minUserRollbackAge,
 		BackendType:       logical.TypeLogical,
 	}
 	b.system = system
+	b.staticRoleBackoff = make(map[string]*rotationBackoff)
+	b.clients = make(map[string]*mongodbatlas.Client)
 	return &b
 }
 
+func (b *Backend) periodicFunc(ctx context.Context, req *logical.Request) error {
+	// Rotations and tidy create and delete Atlas keys and write storage,
+	// so they only run where this mount's storage is writable
+	if !b.canWriteStorage() {
+		return nil
+	}
+
+	var merr error
+	if err := b.rotateRootIfDue(ctx, req.Storage); err != nil {
+		merr = multierror.Append(merr, err)
+	}
+	if err := b.rotateStaticRolesIfDue(ctx, req.Storage); err != nil {
+		merr = multierror.Append(merr, err)
+	}
+	if err := b.tidyIfDue(ctx, req.Storage); err != nil {
+		merr = multierror.Append(merr, err)
+	}
+	return merr
+}
+
+// canWriteStorage reports whether the mount can write its storage on this
+// node, which is not the case on performance standbys and DR secondaries,
+// or for mounts replicated to a performance secondary.
+func (b *Backend) canWriteStorage() bool {
+	replicationState := b.System().ReplicationState()
+	return (b.System().LocalMount() || !replicationState.HasState(consts.ReplicationPerformanceSecondary)) &&
+		!replicationState.HasState(consts.ReplicationDRSecondary) &&
+		!replicationState.HasState(consts.ReplicationPerformanceStandby)
+}
+
+func (b *Backend) invalidate(ctx context.Context, key string) {
+	switch {
+	case key == "config":
+		b.resetClient("")
+	case strings.HasPrefix(key, connectionPath):
+		b.resetClient(strings.TrimPrefix(key, connectionPath))
+	}
+}
+
+func (b *Backend) walRollback(ctx context.Context, req *logical.Request, kind string, data interface{}) error {
+	switch kind {
+	case programmaticAPIKey:
+		return b.pathProgrammaticAPIKeyRollback(ctx, req, kind, data)
+	case databaseUser:
+		return b.databaseUserRollback(ctx, req, data)
+	case rootRotationWALKind:
+		return b.rootRotationRollback(ctx, req, data)
+	case staticRotationWALKind:
+		return b.staticRotationRollback(ctx, req, data)
+	default:
+		return fmt.Errorf("unknown WAL entry kind %q", kind)
+	}
+}
+
 type Backend struct {
 	*framework.Backend
 
 	credentialMutex sync.RWMutex
 	clientMutex     sync.RWMutex
+	rootMutex       sync.Mutex
+	staticRoleMutex sync.Mutex
+	tidyMutex       sync.Mutex
+	mountIDMutex    sync.Mutex
+
+	rootRotationBackoff rotationBackoff
+	staticRoleBackoff   map[string]*rotationBackoff
+	lastTidy            time.Time
+	mountID             string
 
-	client *mongodbatlas.Client
+	clients map[string]*mongodbatlas.Client
 
 	system logical.SystemView
 }
@@ -68,6 +161,12 @@ The MongoDB Atlas backend dynamically generates API keys for a set of
 Organization or Project roles. The API keys have a configurable lease 
 set and are automatically revoked at the end of the lease.
 
+Roles can also generate Database Users for a Project, which are deleted
+at the end of their lease as well.
+
+Static roles manage an existing API key, which is replaced by a new key
+with the same roles on a schedule.
+
 After mounting this backend, the Public and Private keys to generate 
 API keys must be configured with the "config" path and roles must be 
 written  using the "roles/" endpoints before any API keys can be generated.
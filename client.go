@@ -3,6 +3,7 @@ package mongodbatlas
 import (
 	"context"
 	"errors"
+	"net/http"
 
 	"github.com/Sectorbob/mlab-ns2/gae/ns/digest"
 	"github.com/hashicorp/errwrap"
@@ -11,30 +12,49 @@ import (
 )
 
 func (b *Backend) clientMongo(ctx context.Context, s logical.Storage) (*mongodbatlas.Client, error) {
+	return b.connectionClient(ctx, s, "")
+}
+
+// connectionClient returns the client of a named connection, or of the root
+// configuration if the name is empty. Clients are cached per connection.
+func (b *Backend) connectionClient(ctx context.Context, s logical.Storage, name string) (*mongodbatlas.Client, error) {
 	b.clientMutex.Lock()
 	defer b.clientMutex.Unlock()
 
 	// if the client is already created, just return it
-	if b.client != nil {
-		return b.client, nil
+	if client, ok := b.clients[name]; ok {
+		return client, nil
 	}
 
-	client, err := nonCachedClient(ctx, s)
+	client, err := nonCachedClient(ctx, s, name)
 	if err != nil {
 		return nil, err
 	}
-	b.client = client
+	b.clients[name] = client
+
+	return client, nil
+}
 
-	return b.client, nil
+// resetClient clears the cached client of a connection so the next call to
+// connectionClient builds a new one from the stored configuration.
+func (b *Backend) resetClient(name string) {
+	b.clientMutex.Lock()
+	defer b.clientMutex.Unlock()
+
+	delete(b.clients, name)
 }
 
-func nonCachedClient(ctx context.Context, s logical.Storage) (*mongodbatlas.Client, error) {
+func nonCachedClient(ctx context.Context, s logical.Storage, name string) (*mongodbatlas.Client, error) {
 
-	config, err := getRootConfig(ctx, s)
+	config, err := getConnectionConfig(ctx, s, name)
 	if err != nil {
 		return nil, err
 	}
 
+	return newClient(config)
+}
+
+func newClient(config *config) (*mongodbatlas.Client, error) {
 	transport := digest.NewTransport(config.PublicKey, config.PrivateKey)
 
 	client, err := transport.Client()
@@ -42,25 +62,65 @@ func nonCachedClient(ctx context.Context, s logical.Storage) (*mongodbatlas.Clie
 		return nil, err
 	}
 
-	return mongodbatlas.NewClient(client), nil
+	if config.BaseURL == "" {
+		return mongodbatlas.NewClient(client), nil
+	}
+
+	return mongodbatlas.New(client, mongodbatlas.SetBaseURL(config.BaseURL))
 }
 
 func getRootConfig(ctx context.Context, s logical.Storage) (*config, error) {
+	config, err := readRootConfig(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return nil, errors.New("empty config entry")
+	}
 
+	return config, nil
+}
+
+// readRootConfig returns the root configuration, or nil if it hasn't been
+// written yet.
+func readRootConfig(ctx context.Context, s logical.Storage) (*config, error) {
 	entry, err := s.Get(ctx, "config")
 	if err != nil {
 		return nil, err
 	}
-	if entry != nil {
-		var config config
-		if err := entry.DecodeJSON(&config); err != nil {
-			return nil, errwrap.Wrapf("error reading root configuration: {{err}}", err)
-		}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var config config
+	if err := entry.DecodeJSON(&config); err != nil {
+		return nil, errwrap.Wrapf("error reading root configuration: {{err}}", err)
+	}
+
+	return &config, nil
+}
 
-		// return the config, we are done
-		return &config, nil
+// rootResource is the response of the Atlas API root resource, which describes
+// the programmatic API key used to authenticate the request.
+type rootResource struct {
+	APIKey *mongodbatlas.APIKey `json:"apiKey"`
+}
+
+// getRootAPIKey returns the programmatic API key the client authenticates with.
+func getRootAPIKey(ctx context.Context, client *mongodbatlas.Client) (*mongodbatlas.APIKey, error) {
+	req, err := client.NewRequest(ctx, http.MethodGet, "", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	root := new(rootResource)
+	if _, err := client.Do(ctx, req, root); err != nil {
+		return nil, err
+	}
 
+	if root.APIKey == nil || root.APIKey.ID == "" {
+		return nil, errors.New("the Atlas API did not return the programmatic API key in use")
 	}
 
-	return nil, errors.New("empty config entry")
+	return root.APIKey, nil
 }
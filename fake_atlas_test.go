@@ -0,0 +1,431 @@
+package mongodbatlas
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/mongodb/go-client-mongodb-atlas/mongodbatlas"
+)
+
+const (
+	fakeOrganizationID = "5b71ff2f96e82120d0aaec14"
+	fakeProjectID      = "5cf5a45a9ccf6400e60981b6"
+	fakeAPIPath        = "/api/atlas/v1.0/"
+)
+
+// fakeAtlas is an in memory stand-in of the parts of the MongoDB Atlas API
+// used by the backend.
+type fakeAtlas struct {
+	sync.Mutex
+
+	server *httptest.Server
+
+	keys     map[string]*fakeAPIKey
+	projects map[string]string
+	users    map[string]*databaseUserRequest
+	rootKey  string
+	lastID   int
+
+	// failures maps a "METHOD resource" pair to the status code returned
+	// instead of handling the request.
+	failures map[string]int
+}
+
+type fakeAPIKey struct {
+	mongodbatlas.APIKey
+	orgID      string
+	accessList []*mongodbatlas.WhitelistAPIKey
+}
+
+func newFakeAtlas() *fakeAtlas {
+	f := &fakeAtlas{
+		keys: map[string]*fakeAPIKey{},
+		projects: map[string]string{
+			fakeProjectID: fakeOrganizationID,
+		},
+		users:    map[string]*databaseUserRequest{},
+		failures: map[string]int{},
+	}
+
+	root := f.addKey(fakeOrganizationID, "root key", []mongodbatlas.APIKeyRole{
+		{OrgID: fakeOrganizationID, RoleName: "ORG_OWNER"},
+	})
+	root.accessList = []*mongodbatlas.WhitelistAPIKey{
+		{IPAddress: "10.0.0.1", CidrBlock: "10.0.0.1/32"},
+	}
+	f.rootKey = root.ID
+
+	f.server = httptest.NewServer(http.HandlerFunc(f.handle))
+	return f
+}
+
+func (f *fakeAtlas) Close() {
+	f.server.Close()
+}
+
+func (f *fakeAtlas) URL() string {
+	return f.server.URL + fakeAPIPath
+}
+
+func (f *fakeAtlas) fail(method, resource string, status int) {
+	f.Lock()
+	defer f.Unlock()
+	f.failures[method+" "+resource] = status
+}
+
+func (f *fakeAtlas) recover(method, resource string) {
+	f.Lock()
+	defer f.Unlock()
+	delete(f.failures, method+" "+resource)
+}
+
+func (f *fakeAtlas) key(id string) *fakeAPIKey {
+	f.Lock()
+	defer f.Unlock()
+	return f.keys[id]
+}
+
+func (f *fakeAtlas) user(projectID, username string) *databaseUserRequest {
+	f.Lock()
+	defer f.Unlock()
+	return f.users[projectID+"/"+username]
+}
+
+func (f *fakeAtlas) keyCount() int {
+	f.Lock()
+	defer f.Unlock()
+	return len(f.keys)
+}
+
+func (f *fakeAtlas) addKey(orgID, desc string, roles []mongodbatlas.APIKeyRole) *fakeAPIKey {
+	f.lastID++
+	key := &fakeAPIKey{
+		APIKey: mongodbatlas.APIKey{
+			ID:         fmt.Sprintf("%024x", f.lastID),
+			Desc:       desc,
+			Roles:      roles,
+			PublicKey:  fmt.Sprintf("public%02d", f.lastID),
+			PrivateKey: fmt.Sprintf("private-%d", f.lastID),
+		},
+		orgID: orgID,
+	}
+	f.keys[key.ID] = key
+	return key
+}
+
+// view returns the key as returned by the API, without the private key.
+func (k *fakeAPIKey) view() mongodbatlas.APIKey {
+	view := k.APIKey
+	view.PrivateKey = ""
+	return view
+}
+
+func (f *fakeAtlas) handle(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+
+	path := strings.TrimPrefix(r.URL.EscapedPath(), fakeAPIPath)
+	path = strings.TrimSuffix(path, "/")
+	var parts []string
+	if path != "" {
+		parts = strings.Split(path, "/")
+	}
+	for i := range parts {
+		parts[i], _ = url.PathUnescape(parts[i])
+	}
+
+	resource := fakeResource(parts)
+	if status, ok := f.failures[r.Method+" "+resource]; ok {
+		f.writeError(w, status)
+		return
+	}
+
+	var input json.RawMessage
+	if r.Body != nil {
+		_ = json.NewDecoder(r.Body).Decode(&input)
+	}
+
+	switch resource {
+	case "root":
+		root := f.keys[f.rootKey]
+		f.write(w, map[string]interface{}{
+			"appName": "MongoDB Atlas",
+			"apiKey":  root.view(),
+		})
+	case "organization":
+		if parts[1] != fakeOrganizationID {
+			f.writeError(w, http.StatusNotFound)
+			return
+		}
+		f.write(w, map[string]interface{}{"id": parts[1]})
+	case "project":
+		orgID, ok := f.projects[parts[1]]
+		if !ok {
+			f.writeError(w, http.StatusNotFound)
+			return
+		}
+		f.write(w, mongodbatlas.Project{ID: parts[1], OrgID: orgID})
+	case "keys":
+		f.handleKeys(w, r, parts[1], "", input)
+	case "project keys":
+		orgID, ok := f.projects[parts[1]]
+		if !ok {
+			f.writeError(w, http.StatusNotFound)
+			return
+		}
+		f.handleKeys(w, r, orgID, parts[1], input)
+	case "key":
+		key, ok := f.keys[parts[3]]
+		if !ok || key.orgID != parts[1] {
+			f.writeError(w, http.StatusNotFound)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			f.write(w, key.view())
+		case http.MethodPatch:
+			var update mongodbatlas.APIKeyInput
+			_ = json.Unmarshal(input, &update)
+			if update.Desc != "" {
+				key.Desc = update.Desc
+			}
+			f.write(w, key.view())
+		case http.MethodDelete:
+			delete(f.keys, key.ID)
+		}
+	case "project key":
+		key, ok := f.keys[parts[3]]
+		if !ok {
+			f.writeError(w, http.StatusNotFound)
+			return
+		}
+		switch r.Method {
+		case http.MethodPatch:
+			var assign mongodbatlas.AssignAPIKey
+			_ = json.Unmarshal(input, &assign)
+			for _, role := range assign.Roles {
+				key.Roles = append(key.Roles, mongodbatlas.APIKeyRole{GroupID: parts[1], RoleName: role})
+			}
+		case http.MethodDelete:
+			var roles []mongodbatlas.APIKeyRole
+			for _, role := range key.Roles {
+				if role.GroupID != parts[1] {
+					roles = append(roles, role)
+				}
+			}
+			key.Roles = roles
+		}
+	case "access list":
+		key, ok := f.keys[parts[3]]
+		if !ok || key.orgID != parts[1] {
+			f.writeError(w, http.StatusNotFound)
+			return
+		}
+		if r.Method == http.MethodPost {
+			var entries []*mongodbatlas.WhitelistAPIKeysReq
+			_ = json.Unmarshal(input, &entries)
+			for _, entry := range entries {
+				accessEntry := &mongodbatlas.WhitelistAPIKey{IPAddress: entry.IPAddress, CidrBlock: entry.CidrBlock}
+				if entry.IPAddress != "" {
+					accessEntry.CidrBlock = entry.IPAddress + "/32"
+				}
+				key.accessList = append(key.accessList, accessEntry)
+			}
+		}
+		f.write(w, mongodbatlas.WhitelistAPIKeys{Results: key.accessList, TotalCount: len(key.accessList)})
+	case "access list entry":
+		key, ok := f.keys[parts[3]]
+		if !ok || key.orgID != parts[1] {
+			f.writeError(w, http.StatusNotFound)
+			return
+		}
+		var accessList []*mongodbatlas.WhitelistAPIKey
+		for _, entry := range key.accessList {
+			if entry.IPAddress != parts[5] && entry.CidrBlock != parts[5] {
+				accessList = append(accessList, entry)
+			}
+		}
+		key.accessList = accessList
+	case "database users":
+		var user databaseUserRequest
+		_ = json.Unmarshal(input, &user)
+		if _, ok := f.users[parts[1]+"/"+user.Username]; ok {
+			f.writeError(w, http.StatusConflict)
+			return
+		}
+		f.users[parts[1]+"/"+user.Username] = &user
+		f.write(w, user)
+	case "database user":
+		if _, ok := f.users[parts[1]+"/"+parts[4]]; !ok {
+			f.writeError(w, http.StatusNotFound)
+			return
+		}
+		delete(f.users, parts[1]+"/"+parts[4])
+	default:
+		f.writeError(w, http.StatusNotFound)
+	}
+}
+
+func (f *fakeAtlas) handleKeys(w http.ResponseWriter, r *http.Request, orgID, projectID string, input json.RawMessage) {
+	switch r.Method {
+	case http.MethodGet:
+		results := []mongodbatlas.APIKey{}
+		for _, key := range f.keys {
+			if key.orgID != orgID {
+				continue
+			}
+			if projectID != "" && !fakeKeyInProject(key, projectID) {
+				continue
+			}
+			results = append(results, key.view())
+		}
+		sort.Slice(results, func(i, j int) bool {
+			return results[i].ID < results[j].ID
+		})
+		f.writePage(w, r, results)
+	case http.MethodPost:
+		var keyInput mongodbatlas.APIKeyInput
+		_ = json.Unmarshal(input, &keyInput)
+
+		var roles []mongodbatlas.APIKeyRole
+		for _, role := range keyInput.Roles {
+			if projectID != "" {
+				roles = append(roles, mongodbatlas.APIKeyRole{GroupID: projectID, RoleName: role})
+				continue
+			}
+			roles = append(roles, mongodbatlas.APIKeyRole{OrgID: orgID, RoleName: role})
+		}
+		key := f.addKey(orgID, keyInput.Desc, roles)
+		f.write(w, key.APIKey)
+	}
+}
+
+func fakeKeyInProject(key *fakeAPIKey, projectID string) bool {
+	for _, role := range key.Roles {
+		if role.GroupID == projectID {
+			return true
+		}
+	}
+	return false
+}
+
+// fakeResource names the API resource a request path refers to.
+func fakeResource(parts []string) string {
+	switch {
+	case len(parts) == 0:
+		return "root"
+	case len(parts) == 2 && parts[0] == "orgs":
+		return "organization"
+	case len(parts) == 2 && parts[0] == "groups":
+		return "project"
+	case len(parts) == 3 && parts[0] == "orgs" && parts[2] == "apiKeys":
+		return "keys"
+	case len(parts) == 3 && parts[0] == "groups" && parts[2] == "apiKeys":
+		return "project keys"
+	case len(parts) == 4 && parts[0] == "orgs" && parts[2] == "apiKeys":
+		return "key"
+	case len(parts) == 4 && parts[0] == "groups" && parts[2] == "apiKeys":
+		return "project key"
+	case len(parts) == 3 && parts[0] == "groups" && parts[2] == "databaseUsers":
+		return "database users"
+	case len(parts) == 5 && parts[0] == "groups" && parts[2] == "databaseUsers":
+		return "database user"
+	case len(parts) == 5 && parts[0] == "orgs" && parts[4] == "whitelist":
+		return "access list"
+	case len(parts) == 6 && parts[0] == "orgs" && parts[4] == "whitelist":
+		return "access list entry"
+	default:
+		return strings.Join(parts, "/")
+	}
+}
+
+// writePage writes the page of the results requested by the pageNum and
+// itemsPerPage query parameters.
+func (f *fakeAtlas) writePage(w http.ResponseWriter, r *http.Request, results []mongodbatlas.APIKey) {
+	pageNum, err := strconv.Atoi(r.URL.Query().Get("pageNum"))
+	if err != nil || pageNum < 1 {
+		pageNum = 1
+	}
+	itemsPerPage, err := strconv.Atoi(r.URL.Query().Get("itemsPerPage"))
+	if err != nil || itemsPerPage < 1 {
+		itemsPerPage = 100
+	}
+
+	total := len(results)
+	start := (pageNum - 1) * itemsPerPage
+	if start > total {
+		start = total
+	}
+	end := start + itemsPerPage
+	if end > total {
+		end = total
+	}
+
+	links := []*mongodbatlas.Link{}
+	if end < total {
+		links = append(links, &mongodbatlas.Link{Rel: "next", Href: r.URL.String()})
+	}
+
+	f.write(w, map[string]interface{}{
+		"results":    results[start:end],
+		"totalCount": total,
+		"links":      links,
+	})
+}
+
+func (f *fakeAtlas) write(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func (f *fakeAtlas) writeError(w http.ResponseWriter, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":  status,
+		"reason": http.StatusText(status),
+	})
+}
+
+// newFakeAtlasBackend returns a backend configured against a fake Atlas API,
+// which must be closed by the caller.
+func newFakeAtlasBackend(t *testing.T) (*Backend, logical.Storage, *fakeAtlas) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+	config.System = logical.TestSystemView()
+
+	b := NewBackend(config.System)
+	if err := b.Setup(context.Background(), config); err != nil {
+		t.Fatal(err)
+	}
+
+	atlas := newFakeAtlas()
+	root := atlas.key(atlas.rootKey)
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"public_key":  root.PublicKey,
+			"private_key": root.PrivateKey,
+			"base_url":    atlas.URL(),
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		atlas.Close()
+		t.Fatalf("bad: config write failed:. resp:%#v err:%v", resp, err)
+	}
+
+	return b, config.StorageView, atlas
+}
@@ -0,0 +1,194 @@
+package mongodbatlas
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/sdk/helper/base62"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// issuedAPIKeyPath is the storage prefix of the Programmatic API Keys that
+// were created by the backend and haven't been revoked yet.
+const issuedAPIKeyPath = "issued-keys/"
+
+// issuedPublicKeyPath maps the public keys of the issued Programmatic API Keys
+// to their ID.
+const issuedPublicKeyPath = "issued-public-keys/"
+
+// issuedLeaseIDPath maps the hashes of the recorded lease IDs of the issued
+// Programmatic API Keys to their ID. Lease IDs contain slashes, so they are
+// hashed to keep one entry per lease.
+const issuedLeaseIDPath = "issued-lease-ids/"
+
+// issuedAPIKeyEntry records a Programmatic API Key issued by a role. The lease
+// ID isn't known to the backend when the key is issued, so the ID of the
+// request, which the audit log maps to the lease, is recorded instead. The
+// lease ID is recorded once the lease is renewed, if Vault provides it. The
+// lease can't be renewed past its maximum TTL, after which tidy deletes keys
+// that are still recorded.
+type issuedAPIKeyEntry struct {
+	APIKeyID       string        `json:"programmatic_api_key_id"`
+	PublicKey      string        `json:"public_key"`
+	OrganizationID string        `json:"organization_id"`
+	ProjectID      string        `json:"project_id"`
+	Connection     string        `json:"connection"`
+	Description    string        `json:"description"`
+	Role           string        `json:"role"`
+	IssueTime      time.Time     `json:"issue_time"`
+	MaxTTL         time.Duration `json:"max_ttl"`
+	RequestID      string        `json:"request_id"`
+	EntityID       string        `json:"entity_id"`
+	LeaseID        string        `json:"lease_id"`
+}
+
+func (e issuedAPIKeyEntry) toResponseData() map[string]interface{} {
+	return map[string]interface{}{
+		"programmatic_api_key_id": e.APIKeyID,
+		"public_key":              e.PublicKey,
+		"organization_id":         e.OrganizationID,
+		"project_id":              e.ProjectID,
+		"connection":              e.Connection,
+		"description":             e.Description,
+		"role":                    e.Role,
+		"issue_time":              e.IssueTime.Format(time.RFC3339),
+		"max_ttl":                 int64(e.MaxTTL.Seconds()),
+		"request_id":              e.RequestID,
+		"entity_id":               e.EntityID,
+		"lease_id":                e.LeaseID,
+	}
+}
+
+func putIssuedAPIKey(ctx context.Context, s logical.Storage, entry *issuedAPIKeyEntry) error {
+	storageEntry, err := logical.StorageEntryJSON(issuedAPIKeyPath+entry.APIKeyID, entry)
+	if err != nil {
+		return err
+	}
+	if err := s.Put(ctx, storageEntry); err != nil {
+		return err
+	}
+
+	if entry.LeaseID != "" {
+		if err := s.Put(ctx, &logical.StorageEntry{
+			Key:   issuedLeaseIDKey(entry.LeaseID),
+			Value: []byte(entry.APIKeyID),
+		}); err != nil {
+			return err
+		}
+	}
+
+	if entry.PublicKey == "" {
+		return nil
+	}
+	return s.Put(ctx, &logical.StorageEntry{
+		Key:   issuedPublicKeyPath + entry.PublicKey,
+		Value: []byte(entry.APIKeyID),
+	})
+}
+
+func issuedLeaseIDKey(leaseID string) string {
+	hash := sha256.Sum256([]byte(leaseID))
+	return issuedLeaseIDPath + hex.EncodeToString(hash[:])
+}
+
+func getIssuedAPIKey(ctx context.Context, s logical.Storage, keyID string) (*issuedAPIKeyEntry, error) {
+	entry, err := s.Get(ctx, issuedAPIKeyPath+keyID)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var issued issuedAPIKeyEntry
+	if err := entry.DecodeJSON(&issued); err != nil {
+		return nil, err
+	}
+	return &issued, nil
+}
+
+func getIssuedAPIKeyByPublicKey(ctx context.Context, s logical.Storage, publicKey string) (*issuedAPIKeyEntry, error) {
+	entry, err := s.Get(ctx, issuedPublicKeyPath+publicKey)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+	return getIssuedAPIKey(ctx, s, string(entry.Value))
+}
+
+// getIssuedAPIKeyByLeaseID returns the key with the given lease ID, which is
+// only recorded once the lease has been renewed.
+func getIssuedAPIKeyByLeaseID(ctx context.Context, s logical.Storage, leaseID string) (*issuedAPIKeyEntry, error) {
+	entry, err := s.Get(ctx, issuedLeaseIDKey(leaseID))
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+	return getIssuedAPIKey(ctx, s, string(entry.Value))
+}
+
+func deleteIssuedAPIKey(ctx context.Context, s logical.Storage, keyID string) error {
+	issued, err := getIssuedAPIKey(ctx, s, keyID)
+	if err != nil {
+		return err
+	}
+	if issued == nil {
+		return nil
+	}
+
+	if issued.PublicKey != "" {
+		if err := s.Delete(ctx, issuedPublicKeyPath+issued.PublicKey); err != nil {
+			return err
+		}
+	}
+	if issued.LeaseID != "" {
+		if err := s.Delete(ctx, issuedLeaseIDKey(issued.LeaseID)); err != nil {
+			return err
+		}
+	}
+	return s.Delete(ctx, issuedAPIKeyPath+keyID)
+}
+
+// mountIDPath stores a random identifier of the mount. It is added to the
+// descriptions of the Programmatic API Keys issued by the mount, so that keys
+// issued by other mounts in the same organization are never tidied.
+const mountIDPath = "mount-id"
+
+// getMountID returns the identifier of the mount, generating it on first use.
+func (b *Backend) getMountID(ctx context.Context, s logical.Storage) (string, error) {
+	b.mountIDMutex.Lock()
+	defer b.mountIDMutex.Unlock()
+
+	if b.mountID != "" {
+		return b.mountID, nil
+	}
+
+	entry, err := s.Get(ctx, mountIDPath)
+	if err != nil {
+		return "", err
+	}
+	if entry != nil {
+		b.mountID = string(entry.Value)
+		return b.mountID, nil
+	}
+
+	id, err := base62.Random(8)
+	if err != nil {
+		return "", errwrap.Wrapf("error generating mount ID: {{err}}", err)
+	}
+	if err := s.Put(ctx, &logical.StorageEntry{
+		Key:   mountIDPath,
+		Value: []byte(id),
+	}); err != nil {
+		return "", err
+	}
+	b.mountID = id
+	return id, nil
+}
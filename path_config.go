@@ -3,6 +3,10 @@ package mongodbatlas
 import (
 	"context"
 	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
@@ -25,6 +29,39 @@ func (b *Backend) pathConfig() *framework.Path {
 					Sensitive: true,
 				},
 			},
+			"base_url": {
+				Type:        framework.TypeString,
+				Description: "Base URL of the MongoDB Atlas API, used to reach Ops Manager or Cloud Manager. Defaults to the MongoDB Atlas API.",
+			},
+			"verify_connection": {
+				Type:        framework.TypeBool,
+				Default:     true,
+				Description: "If true, the credentials are verified against the MongoDB Atlas API before they are stored. Defaults to true.",
+			},
+			"rotation_period": {
+				Type:        framework.TypeDurationSecond,
+				Description: "Period after which the Programmatic API Key is automatically rotated. Defaults to 0, in which case the key is never rotated automatically.",
+			},
+			"tidy_interval": {
+				Type:        framework.TypeDurationSecond,
+				Description: "Interval at which orphaned Programmatic API Keys are deleted automatically. Defaults to 0, in which case they are only deleted through the tidy/keys endpoint.",
+			},
+			"allowed_org_roles": {
+				Type:        framework.TypeCommaStringSlice,
+				Description: "Organization roles that roles using these credentials may grant. Defaults to all of them.",
+			},
+			"allowed_project_roles": {
+				Type:        framework.TypeCommaStringSlice,
+				Description: "Project roles that roles using these credentials may grant. Defaults to all of them.",
+			},
+			"allowed_organization_ids": {
+				Type:        framework.TypeCommaStringSlice,
+				Description: "Organizations that roles using these credentials may issue credentials in. Defaults to all of them.",
+			},
+			"allowed_project_ids": {
+				Type:        framework.TypeCommaStringSlice,
+				Description: "Projects that roles using these credentials may issue credentials in. Defaults to all of them.",
+			},
 		},
 		Callbacks: map[logical.Operation]framework.OperationFunc{
 			logical.UpdateOperation: b.pathConfigWrite,
@@ -36,32 +73,121 @@ func (b *Backend) pathConfig() *framework.Path {
 }
 
 func (b *Backend) pathConfigWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	publicKey := data.Get("public_key").(string)
-	if publicKey == "" {
-		return nil, errors.New("public_key is empty")
+	b.rootMutex.Lock()
+	defer b.rootMutex.Unlock()
+
+	cfg, err := readRootConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
 	}
 
-	privateKey := data.Get("private_key").(string)
-	if privateKey == "" {
-		return nil, errors.New("private_key is empty")
+	// The keys can be left out when updating an existing configuration, as
+	// once they are rotated they are only known to Vault.
+	_, publicKeyOk := data.GetOk("public_key")
+	_, privateKeyOk := data.GetOk("private_key")
+	if cfg == nil || publicKeyOk || privateKeyOk {
+		publicKey := data.Get("public_key").(string)
+		if publicKey == "" {
+			return nil, errors.New("public_key is empty")
+		}
+
+		privateKey := data.Get("private_key").(string)
+		if privateKey == "" {
+			return nil, errors.New("private_key is empty")
+		}
+
+		if cfg == nil {
+			cfg = &config{}
+		}
+		cfg.PublicKey = publicKey
+		cfg.PrivateKey = privateKey
+		cfg.LastRotated = time.Now().UTC()
 	}
 
-	entry, err := logical.StorageEntryJSON("config", config{
-		PublicKey:  publicKey,
-		PrivateKey: privateKey,
-	})
-	if err != nil {
-		return nil, err
+	if baseURLRaw, ok := data.GetOk("base_url"); ok {
+		baseURL, err := normalizeBaseURL(baseURLRaw.(string))
+		if err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+		cfg.BaseURL = baseURL
 	}
 
-	if err := req.Storage.Put(ctx, entry); err != nil {
+	if rotationPeriodRaw, ok := data.GetOk("rotation_period"); ok {
+		cfg.RotationPeriod = time.Duration(rotationPeriodRaw.(int)) * time.Second
+	}
+	if cfg.RotationPeriod < 0 {
+		return logical.ErrorResponse("rotation_period must not be negative"), nil
+	}
+
+	if tidyIntervalRaw, ok := data.GetOk("tidy_interval"); ok {
+		cfg.TidyInterval = time.Duration(tidyIntervalRaw.(int)) * time.Second
+	}
+	if cfg.TidyInterval < 0 {
+		return logical.ErrorResponse("tidy_interval must not be negative"), nil
+	}
+
+	updateAllowLists(cfg, data)
+
+	if cfg.LastRotated.IsZero() {
+		cfg.LastRotated = time.Now().UTC()
+	}
+
+	var resp *logical.Response
+	if data.Get("verify_connection").(bool) {
+		orgID, err := verifyRootConfig(ctx, cfg)
+		if err != nil {
+			return logical.ErrorResponse("error verifying the MongoDB Atlas credentials: %s", err), nil
+		}
+		cfg.OrganizationID = orgID
+
+		resp = &logical.Response{
+			Data: map[string]interface{}{
+				"organization_id": orgID,
+			},
+		}
+	}
+
+	if err := putRootConfig(ctx, req.Storage, cfg); err != nil {
 		return nil, err
 	}
 
 	// Clean cached client (if any)
-	b.client = nil
+	b.resetClient("")
 
-	return nil, nil
+	return resp, nil
+}
+
+// updateAllowLists sets the allow-lists of a configuration that were
+// supplied.
+func updateAllowLists(cfg *config, data *framework.FieldData) {
+	if allowedOrgRoles, ok := data.GetOk("allowed_org_roles"); ok {
+		cfg.AllowedOrgRoles = allowedOrgRoles.([]string)
+	}
+	if allowedProjectRoles, ok := data.GetOk("allowed_project_roles"); ok {
+		cfg.AllowedProjectRoles = allowedProjectRoles.([]string)
+	}
+	if allowedOrganizationIDs, ok := data.GetOk("allowed_organization_ids"); ok {
+		cfg.AllowedOrganizationIDs = allowedOrganizationIDs.([]string)
+	}
+	if allowedProjectIDs, ok := data.GetOk("allowed_project_ids"); ok {
+		cfg.AllowedProjectIDs = allowedProjectIDs.([]string)
+	}
+}
+
+// verifyRootConfig makes an authenticated call to the MongoDB Atlas API with
+// the configured credentials, and returns the organization they belong to.
+func verifyRootConfig(ctx context.Context, cfg *config) (string, error) {
+	client, err := newClient(cfg)
+	if err != nil {
+		return "", err
+	}
+
+	key, err := getRootAPIKey(ctx, client)
+	if err != nil {
+		return "", err
+	}
+
+	return rootOrganizationID(key), nil
 }
 
 func (b *Backend) pathConfigRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
@@ -72,14 +198,63 @@ func (b *Backend) pathConfigRead(ctx context.Context, req *logical.Request, data
 
 	return &logical.Response{
 		Data: map[string]interface{}{
-			"public_key": cfg.PublicKey,
+			"public_key":               cfg.PublicKey,
+			"organization_id":          cfg.OrganizationID,
+			"base_url":                 cfg.BaseURL,
+			"rotation_period":          int64(cfg.RotationPeriod.Seconds()),
+			"last_rotated":             cfg.LastRotated.Format(time.RFC3339),
+			"tidy_interval":            int64(cfg.TidyInterval.Seconds()),
+			"allowed_org_roles":        cfg.AllowedOrgRoles,
+			"allowed_project_roles":    cfg.AllowedProjectRoles,
+			"allowed_organization_ids": cfg.AllowedOrganizationIDs,
+			"allowed_project_ids":      cfg.AllowedProjectIDs,
 		},
 	}, nil
 }
 
+// normalizeBaseURL validates an API base URL and makes sure it ends with a
+// slash, as the client resolves the API paths relative to it.
+func normalizeBaseURL(baseURL string) (string, error) {
+	if baseURL == "" {
+		return "", nil
+	}
+
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid base_url: %s", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("invalid base_url %q: an http or https URL is required", baseURL)
+	}
+
+	if !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+	}
+
+	return u.String(), nil
+}
+
+func putRootConfig(ctx context.Context, s logical.Storage, cfg *config) error {
+	entry, err := logical.StorageEntryJSON("config", cfg)
+	if err != nil {
+		return err
+	}
+
+	return s.Put(ctx, entry)
+}
+
 type config struct {
-	PrivateKey string `json:"private_key"`
-	PublicKey  string `json:"public_key"`
+	PrivateKey             string        `json:"private_key"`
+	PublicKey              string        `json:"public_key"`
+	OrganizationID         string        `json:"organization_id"`
+	BaseURL                string        `json:"base_url"`
+	RotationPeriod         time.Duration `json:"rotation_period"`
+	LastRotated            time.Time     `json:"last_rotated"`
+	TidyInterval           time.Duration `json:"tidy_interval"`
+	AllowedOrgRoles        []string      `json:"allowed_org_roles"`
+	AllowedProjectRoles    []string      `json:"allowed_project_roles"`
+	AllowedOrganizationIDs []string      `json:"allowed_organization_ids"`
+	AllowedProjectIDs      []string      `json:"allowed_project_ids"`
 }
 
 const pathConfigHelpSyn = `
@@ -90,4 +265,25 @@ const pathConfigHelpDesc = `
 Before doing anything, the Atlas backend needs credentials that are able
 to manage databaseusers, access keys, etc. This endpoint is used to 
 configure those credentials.
+
+Unless "verify_connection" is false, the credentials are verified against
+the MongoDB Atlas API before they are stored, and the organization they
+belong to is returned.
+
+"base_url" points the backend to a different MongoDB Atlas API endpoint,
+such as an Ops Manager or Cloud Manager installation.
+
+If "rotation_period" is set, the Programmatic API Key is rotated
+automatically once the period has elapsed since it was last rotated.
+
+If "tidy_interval" is set, the Programmatic API Keys created by the
+backend that have no active lease are deleted at that interval.
+
+"allowed_org_roles", "allowed_project_roles", "allowed_organization_ids"
+and "allowed_project_ids" restrict the roles using these credentials to the
+listed MongoDB Atlas roles, organizations and projects, so that role authors
+can't grant more than approved. Roles are checked when they are written and
+again when credentials are issued, so roles written before the lists were
+changed can't issue credentials outside of them. Named connections have
+allow-lists of their own.
 `
@@ -0,0 +1,109 @@
+package mongodbatlas
+
+import (
+	"context"
+	"time"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func (b *Backend) pathConfigLease() *framework.Path {
+	return &framework.Path{
+		Pattern: "config/lease",
+		Fields: map[string]*framework.FieldSchema{
+			"ttl": {
+				Type:        framework.TypeDurationSecond,
+				Description: "Default lease for generated credentials. If not set or set to 0, will use system default.",
+			},
+			"max_ttl": {
+				Type:        framework.TypeDurationSecond,
+				Description: "Maximum time a credential is valid for. If not set or set to 0, will use system default.",
+			},
+		},
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathConfigLeaseRead,
+			logical.UpdateOperation: b.pathConfigLeaseWrite,
+			logical.DeleteOperation: b.pathConfigLeaseDelete,
+		},
+		HelpSynopsis:    pathConfigLeaseHelpSyn,
+		HelpDescription: pathConfigLeaseHelpDesc,
+	}
+}
+
+func (b *Backend) pathConfigLeaseWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	leaseConfig := &configLease{
+		TTL:    time.Duration(data.Get("ttl").(int)) * time.Second,
+		MaxTTL: time.Duration(data.Get("max_ttl").(int)) * time.Second,
+	}
+
+	if leaseConfig.MaxTTL > 0 && leaseConfig.TTL > leaseConfig.MaxTTL {
+		return logical.ErrorResponse("ttl exceeds max_ttl"), nil
+	}
+
+	entry, err := logical.StorageEntryJSON("config/lease", leaseConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := req.Storage.Put(ctx, entry); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (b *Backend) pathConfigLeaseRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	leaseConfig, err := getLeaseConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if leaseConfig == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"ttl":     int64(leaseConfig.TTL.Seconds()),
+			"max_ttl": int64(leaseConfig.MaxTTL.Seconds()),
+		},
+	}, nil
+}
+
+func (b *Backend) pathConfigLeaseDelete(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	err := req.Storage.Delete(ctx, "config/lease")
+	return nil, err
+}
+
+func getLeaseConfig(ctx context.Context, s logical.Storage) (*configLease, error) {
+	entry, err := s.Get(ctx, "config/lease")
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var leaseConfig configLease
+	if err := entry.DecodeJSON(&leaseConfig); err != nil {
+		return nil, errwrap.Wrapf("error reading lease configuration: {{err}}", err)
+	}
+
+	return &leaseConfig, nil
+}
+
+type configLease struct {
+	TTL    time.Duration `json:"ttl"`
+	MaxTTL time.Duration `json:"max_ttl"`
+}
+
+const pathConfigLeaseHelpSyn = `
+Configure the default and maximum lease of generated credentials.
+`
+
+const pathConfigLeaseHelpDesc = `
+Sets the default and maximum lease of the credentials generated by this
+backend. Role level "ttl" and "max_ttl" take precedence over these values,
+and these values take precedence over the system/mount defaults.
+`
@@ -0,0 +1,104 @@
+package mongodbatlas
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func TestBackend_PathConfigLease(t *testing.T) {
+	var resp *logical.Response
+	var err error
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+
+	b := NewBackend(config.System)
+	if err := b.Setup(context.Background(), config); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without a lease configuration the system defaults are used
+	defaultLease, maxLease, err := b.getDefaultAndMaxLease(context.Background(), config.StorageView)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if defaultLease != config.System.DefaultLeaseTTL() || maxLease != config.System.MaxLeaseTTL() {
+		t.Fatalf("bad lease: default %s, max %s", defaultLease, maxLease)
+	}
+
+	// Test write operation
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/lease",
+		Data: map[string]interface{}{
+			"ttl":     "80s",
+			"max_ttl": "160s",
+		},
+		Storage: config.StorageView,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("lease config write failed:. resp:%#v err:%v", resp, err)
+	}
+
+	// Test read operation
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "config/lease",
+		Storage:   config.StorageView,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("lease config read failed:. resp:%#v err:%v", resp, err)
+	}
+
+	expected := map[string]interface{}{
+		"ttl":     int64(80),
+		"max_ttl": int64(160),
+	}
+	if diff := deep.Equal(expected, resp.Data); diff != nil {
+		t.Fatalf("bad response. expected %v, got: %v", expected, resp.Data)
+	}
+
+	defaultLease, maxLease, err = b.getDefaultAndMaxLease(context.Background(), config.StorageView)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if defaultLease != 80*time.Second || maxLease != 160*time.Second {
+		t.Fatalf("bad lease: default %s, max %s", defaultLease, maxLease)
+	}
+
+	// ttl greater than max_ttl
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/lease",
+		Data: map[string]interface{}{
+			"ttl":     "200s",
+			"max_ttl": "160s",
+		},
+		Storage: config.StorageView,
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected error response. resp:%#v err:%v", resp, err)
+	}
+
+	// Test delete operation
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.DeleteOperation,
+		Path:      "config/lease",
+		Storage:   config.StorageView,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("lease config delete failed:. resp:%#v err:%v", resp, err)
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "config/lease",
+		Storage:   config.StorageView,
+	})
+	if err != nil || resp != nil {
+		t.Fatalf("expected no lease config. resp:%#v err:%v", resp, err)
+	}
+}
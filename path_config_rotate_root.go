@@ -0,0 +1,288 @@
+package mongodbatlas
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/base62"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/mitchellh/mapstructure"
+	"github.com/mongodb/go-client-mongodb-atlas/mongodbatlas"
+)
+
+const rootRotationWALKind = "root_rotation"
+
+// rootRotationDescriptionPrefix starts the description of a root key being
+// rotated in, which is unique to the rotation until it completes.
+const rootRotationDescriptionPrefix = "vault-root-rotation-"
+
+const (
+	minRotationBackoff = time.Minute
+	maxRotationBackoff = time.Hour
+)
+
+func (b *Backend) pathConfigRotateRoot() *framework.Path {
+	return &framework.Path{
+		Pattern: "config/rotate-root",
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathConfigRotateRootUpdate,
+		},
+		HelpSynopsis:    pathConfigRotateRootHelpSyn,
+		HelpDescription: pathConfigRotateRootHelpDesc,
+	}
+}
+
+func (b *Backend) pathConfigRotateRootUpdate(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	publicKey, err := b.rotateRootCredentials(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"public_key": publicKey,
+		},
+	}, nil
+}
+
+// rotateRootCredentials replaces the root programmatic API key with a new one
+// that has the same roles and access list, and returns its public key.
+//
+// A WAL entry is written before the new key is created, and the key is
+// created with a unique description recorded in it, so if the rotation is
+// interrupted the rollback finds the new key even when its ID was never
+// recorded. The description of the old key is restored once the new key is
+// in use.
+func (b *Backend) rotateRootCredentials(ctx context.Context, s logical.Storage) (string, error) {
+	b.rootMutex.Lock()
+	defer b.rootMutex.Unlock()
+
+	cfg, err := getRootConfig(ctx, s)
+	if err != nil {
+		return "", err
+	}
+
+	client, err := b.clientMongo(ctx, s)
+	if err != nil {
+		return "", err
+	}
+
+	rootKey, err := getRootAPIKey(ctx, client)
+	if err != nil {
+		return "", errwrap.Wrapf("error reading root programmatic API key: {{err}}", err)
+	}
+
+	orgID := rootOrganizationID(rootKey)
+	if orgID == "" {
+		orgID = cfg.OrganizationID
+	}
+	if orgID == "" {
+		return "", errors.New("unable to determine the organization of the root programmatic API key")
+	}
+
+	template, err := getAPIKeyTemplate(ctx, client, orgID, rootKey.ID)
+	if err != nil {
+		return "", err
+	}
+
+	marker, err := base62.Random(20)
+	if err != nil {
+		return "", errwrap.Wrapf("error generating rotation marker: {{err}}", err)
+	}
+	entry := &rootRotationWALEntry{
+		OrganizationID: orgID,
+		OldAPIKeyID:    rootKey.ID,
+		OldPublicKey:   rootKey.PublicKey,
+		Description:    rootRotationDescriptionPrefix + marker,
+	}
+	walID, err := framework.PutWAL(ctx, s, rootRotationWALKind, entry)
+	if err != nil {
+		return "", errwrap.Wrapf("error writing WAL entry: {{err}}", err)
+	}
+
+	// From here on a failure leaves the WAL entry in place so the rollback
+	// removes the new key.
+	newKey, err := createAPIKeyFromTemplate(ctx, client, &apiKeyTemplate{
+		OrganizationID: template.OrganizationID,
+		Description:    entry.Description,
+		Roles:          template.Roles,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	// Record the new key, so the rollback can tell whether it is in use
+	entry.NewAPIKeyID = newKey.ID
+	entry.NewPublicKey = newKey.PublicKey
+	newWALID, err := framework.PutWAL(ctx, s, rootRotationWALKind, entry)
+	if err != nil {
+		return "", errwrap.Wrapf("error writing WAL entry: {{err}}", err)
+	}
+	if err := framework.DeleteWAL(ctx, s, walID); err != nil {
+		return "", errwrap.Wrapf("error deleting WAL entry: {{err}}", err)
+	}
+	walID = newWALID
+
+	if err := applyAPIKeyTemplate(ctx, client, template, newKey.ID); err != nil {
+		return "", err
+	}
+
+	cfg.PublicKey = newKey.PublicKey
+	cfg.PrivateKey = newKey.PrivateKey
+	cfg.LastRotated = time.Now().UTC()
+	if err := putRootConfig(ctx, s, cfg); err != nil {
+		return "", errwrap.Wrapf("error storing new root credentials: {{err}}", err)
+	}
+
+	b.resetClient("")
+
+	client, err = b.clientMongo(ctx, s)
+	if err != nil {
+		return "", err
+	}
+
+	// The new key is in use, a failure here leaves the WAL entry in place so
+	// the rollback retries deleting the old key.
+	if err := deleteAPIKey(ctx, client, orgID, rootKey.ID); err != nil {
+		return "", errwrap.Wrapf("error deleting old root programmatic API key: {{err}}", err)
+	}
+
+	if _, _, err := client.APIKeys.Update(ctx, orgID, newKey.ID, &mongodbatlas.APIKeyInput{
+		Desc:  template.Description,
+		Roles: template.Roles,
+	}); err != nil {
+		b.Logger().Warn("error restoring the description of the root programmatic API key", "error", err)
+	}
+
+	if err := framework.DeleteWAL(ctx, s, walID); err != nil {
+		return "", errwrap.Wrapf("failed to commit WAL entry: {{err}}", err)
+	}
+
+	return newKey.PublicKey, nil
+}
+
+// rootRotationRollback deletes whichever keys of an interrupted rotation are
+// not in use. The stored credentials are compared against both keys, rather
+// than assuming that they are one of them, as a later rotation may have
+// completed before the entry is rolled back.
+func (b *Backend) rootRotationRollback(ctx context.Context, req *logical.Request, data interface{}) error {
+	var entry rootRotationWALEntry
+	if err := mapstructure.Decode(data, &entry); err != nil {
+		return err
+	}
+
+	b.rootMutex.Lock()
+	defer b.rootMutex.Unlock()
+
+	cfg, err := getRootConfig(ctx, req.Storage)
+	if err != nil {
+		return err
+	}
+
+	client, err := b.clientMongo(ctx, req.Storage)
+	if err != nil {
+		return err
+	}
+
+	newKeyID, newPublicKey := entry.NewAPIKeyID, entry.NewPublicKey
+	if newKeyID == "" {
+		key, err := findAPIKeyByDescription(ctx, client, entry.OrganizationID, entry.Description)
+		if err != nil {
+			return err
+		}
+		if key != nil {
+			newKeyID, newPublicKey = key.ID, key.PublicKey
+		}
+	}
+
+	if newKeyID != "" && newPublicKey != cfg.PublicKey {
+		if err := deleteAPIKey(ctx, client, entry.OrganizationID, newKeyID); err != nil {
+			return err
+		}
+	}
+	if entry.OldPublicKey != cfg.PublicKey {
+		return deleteAPIKey(ctx, client, entry.OrganizationID, entry.OldAPIKeyID)
+	}
+	return nil
+}
+
+func rootOrganizationID(key *mongodbatlas.APIKey) string {
+	for _, role := range key.Roles {
+		if role.OrgID != "" {
+			return role.OrgID
+		}
+	}
+	return ""
+}
+
+type rootRotationWALEntry struct {
+	OrganizationID string
+	OldAPIKeyID    string
+	OldPublicKey   string
+	NewAPIKeyID    string
+	NewPublicKey   string
+	Description    string
+}
+
+const pathConfigRotateRootHelpSyn = `
+Request to rotate the root credentials Vault uses for MongoDB Atlas.
+`
+
+const pathConfigRotateRootHelpDesc = `
+This path attempts to rotate the root credentials of the MongoDB Atlas backend.
+A new Programmatic API Key with the same roles, project assignments and
+whitelist entries as the current one is created and stored, and the old key
+is deleted from MongoDB Atlas.
+`
+
+// rotateRootIfDue rotates the root credentials once the configured rotation
+// period has elapsed. Failed rotations are retried with an exponential backoff.
+func (b *Backend) rotateRootIfDue(ctx context.Context, s logical.Storage) error {
+	cfg, err := readRootConfig(ctx, s)
+	if err != nil {
+		return err
+	}
+	if cfg == nil || cfg.RotationPeriod == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	if now.Before(cfg.LastRotated.Add(cfg.RotationPeriod)) || now.Before(b.rootRotationBackoff.next) {
+		return nil
+	}
+
+	if _, err := b.rotateRootCredentials(ctx, s); err != nil {
+		delay := b.rootRotationBackoff.failed(now)
+		return errwrap.Wrapf(fmt.Sprintf("error rotating root credentials, retrying in %s: {{err}}", delay), err)
+	}
+
+	b.rootRotationBackoff.reset()
+	b.Logger().Info("rotated root credentials", "rotation_period", cfg.RotationPeriod)
+	return nil
+}
+
+// rotationBackoff tracks the failed attempts of a periodic rotation.
+type rotationBackoff struct {
+	failures int
+	next     time.Time
+}
+
+// failed records a failed attempt and returns the delay until the next one.
+func (r *rotationBackoff) failed(now time.Time) time.Duration {
+	delay := minRotationBackoff << uint(r.failures)
+	if delay > maxRotationBackoff || delay <= 0 {
+		delay = maxRotationBackoff
+	}
+	r.failures++
+	r.next = now.Add(delay)
+	return delay
+}
+
+func (r *rotationBackoff) reset() {
+	r.failures = 0
+	r.next = time.Time{}
+}
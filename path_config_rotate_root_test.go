@@ -0,0 +1,344 @@
+package mongodbatlas
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/consts"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func TestBackend_PathConfigRotateRoot(t *testing.T) {
+	b, storage, atlas := newFakeAtlasBackend(t)
+	defer atlas.Close()
+
+	oldKeyID := atlas.rootKey
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/rotate-root",
+		Storage:   storage,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: root rotation failed:. resp:%#v err:%v", resp, err)
+	}
+
+	if atlas.key(oldKeyID) != nil {
+		t.Fatal("expected the old root key to be deleted")
+	}
+	if atlas.keyCount() != 1 {
+		t.Fatalf("expected a single key, got %d", atlas.keyCount())
+	}
+
+	cfg, err := getRootConfig(context.Background(), storage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.PublicKey != resp.Data["public_key"] {
+		t.Fatalf("expected the new public key to be stored, got %q", cfg.PublicKey)
+	}
+
+	for _, key := range atlas.keys {
+		if key.PublicKey != cfg.PublicKey || key.PrivateKey != cfg.PrivateKey {
+			t.Fatal("expected the stored credentials to match the new key")
+		}
+		if len(key.Roles) != 1 || key.Roles[0].RoleName != "ORG_OWNER" {
+			t.Fatalf("bad: unexpected roles %v", key.Roles)
+		}
+		if len(key.accessList) != 1 || key.accessList[0].CidrBlock != "10.0.0.1/32" {
+			t.Fatalf("bad: unexpected access list %v", key.accessList)
+		}
+		if key.Desc != "root key" {
+			t.Fatalf("expected the description of the old key to be restored, got %q", key.Desc)
+		}
+	}
+
+	wals, err := framework.ListWAL(context.Background(), storage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(wals) != 0 {
+		t.Fatalf("expected no WAL entries, got %d", len(wals))
+	}
+}
+
+func TestBackend_PathConfigRotateRoot_Rollback(t *testing.T) {
+	b, storage, atlas := newFakeAtlasBackend(t)
+	defer atlas.Close()
+
+	oldKeyID := atlas.rootKey
+	oldCfg, err := getRootConfig(context.Background(), storage)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Fail after the new key is created
+	atlas.fail(http.MethodPost, "access list", http.StatusInternalServerError)
+
+	_, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/rotate-root",
+		Storage:   storage,
+	})
+	if err == nil {
+		t.Fatal("expected root rotation to fail")
+	}
+	if atlas.keyCount() != 2 {
+		t.Fatalf("expected the new key to be left for the rollback, got %d keys", atlas.keyCount())
+	}
+
+	_, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.RollbackOperation,
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"immediate": true,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if atlas.keyCount() != 1 || atlas.key(oldKeyID) == nil {
+		t.Fatal("expected only the old root key to remain")
+	}
+
+	cfg, err := getRootConfig(context.Background(), storage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.PublicKey != oldCfg.PublicKey {
+		t.Fatal("expected the old root credentials to remain in use")
+	}
+}
+
+func TestBackend_PathConfigRotateRoot_RollbackByDescription(t *testing.T) {
+	b, storage, atlas := newFakeAtlasBackend(t)
+	defer atlas.Close()
+
+	root := atlas.key(atlas.rootKey)
+
+	// A rotation interrupted after creating the new key, before its ID was
+	// recorded
+	atlas.Lock()
+	newKey := atlas.addKey(fakeOrganizationID, rootRotationDescriptionPrefix+"aaaaaaaaaaaaaaaaaaaa", nil)
+	atlas.Unlock()
+	if _, err := framework.PutWAL(context.Background(), storage, rootRotationWALKind, &rootRotationWALEntry{
+		OrganizationID: fakeOrganizationID,
+		OldAPIKeyID:    root.ID,
+		OldPublicKey:   root.PublicKey,
+		Description:    newKey.Desc,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.RollbackOperation,
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"immediate": true,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if atlas.key(newKey.ID) != nil || atlas.key(root.ID) == nil {
+		t.Fatal("expected the new key to be deleted and the root key to remain")
+	}
+}
+
+func TestBackend_PathConfigRotateRoot_RollbackAfterLaterRotation(t *testing.T) {
+	b, storage, atlas := newFakeAtlasBackend(t)
+	defer atlas.Close()
+
+	oldKeyID := atlas.rootKey
+
+	// The first rotation stores the new key but fails to delete the old one
+	atlas.fail(http.MethodDelete, "key", http.StatusInternalServerError)
+	_, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/rotate-root",
+		Storage:   storage,
+	})
+	if err == nil {
+		t.Fatal("expected root rotation to fail")
+	}
+	atlas.recover(http.MethodDelete, "key")
+
+	// The fake API doesn't authenticate requests, so it is pointed to the
+	// stored key for the next rotation
+	cfg, err := getRootConfig(context.Background(), storage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	atlas.Lock()
+	for _, key := range atlas.keys {
+		if key.PublicKey == cfg.PublicKey {
+			atlas.rootKey = key.ID
+		}
+	}
+	atlas.Unlock()
+
+	// A later rotation completes before the first one is rolled back
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/rotate-root",
+		Storage:   storage,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: root rotation failed:. resp:%#v err:%v", resp, err)
+	}
+	if atlas.key(oldKeyID) == nil {
+		t.Fatal("expected the key of the first rotation to be left for the rollback")
+	}
+
+	_, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.RollbackOperation,
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"immediate": true,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if atlas.keyCount() != 1 || atlas.key(oldKeyID) != nil {
+		t.Fatalf("expected only the current root key to remain, got %d keys", atlas.keyCount())
+	}
+	for _, key := range atlas.keys {
+		if key.PublicKey != resp.Data["public_key"] {
+			t.Fatalf("expected the current root key %q to remain, got %q", resp.Data["public_key"], key.PublicKey)
+		}
+	}
+}
+
+func TestBackend_RotateRootIfDue(t *testing.T) {
+	b, storage, atlas := newFakeAtlasBackend(t)
+	defer atlas.Close()
+
+	oldKeyID := atlas.rootKey
+	cfg, err := getRootConfig(context.Background(), storage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldPublicKey := cfg.PublicKey
+	cfg.RotationPeriod = time.Hour
+	cfg.LastRotated = time.Now().Add(-2 * time.Hour).UTC()
+	if err := putRootConfig(context.Background(), storage, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	// A failed rotation is retried after a backoff
+	atlas.fail(http.MethodPost, "keys", http.StatusInternalServerError)
+	if err := b.rotateRootIfDue(context.Background(), storage); err == nil {
+		t.Fatal("expected the due rotation to fail")
+	}
+	if b.rootRotationBackoff.failures != 1 || time.Until(b.rootRotationBackoff.next) <= 0 {
+		t.Fatalf("expected a backoff after the failure, got %+v", b.rootRotationBackoff)
+	}
+	atlas.recover(http.MethodPost, "keys")
+
+	if err := b.rotateRootIfDue(context.Background(), storage); err != nil {
+		t.Fatal(err)
+	}
+	if cfg, err = getRootConfig(context.Background(), storage); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.PublicKey != oldPublicKey {
+		t.Fatal("expected no rotation before the backoff elapsed")
+	}
+
+	b.rootRotationBackoff.next = time.Now().Add(-time.Second)
+	before := time.Now().UTC()
+	if err := b.rotateRootIfDue(context.Background(), storage); err != nil {
+		t.Fatal(err)
+	}
+	if cfg, err = getRootConfig(context.Background(), storage); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.PublicKey == oldPublicKey || atlas.key(oldKeyID) != nil {
+		t.Fatal("expected the root key to be rotated once the backoff elapsed")
+	}
+	if cfg.LastRotated.Before(before) {
+		t.Fatalf("expected last_rotated to be updated, got %s", cfg.LastRotated)
+	}
+	if b.rootRotationBackoff.failures != 0 || !b.rootRotationBackoff.next.IsZero() {
+		t.Fatalf("expected the backoff to be reset, got %+v", b.rootRotationBackoff)
+	}
+}
+
+func TestBackend_PeriodicFunc_ReplicationState(t *testing.T) {
+	b, storage, atlas := newFakeAtlasBackend(t)
+	defer atlas.Close()
+
+	cfg, err := getRootConfig(context.Background(), storage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldPublicKey := cfg.PublicKey
+	cfg.RotationPeriod = time.Hour
+	cfg.LastRotated = time.Now().Add(-2 * time.Hour).UTC()
+	if err := putRootConfig(context.Background(), storage, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	system := b.System().(*logical.StaticSystemView)
+	for _, state := range []consts.ReplicationState{
+		consts.ReplicationPerformanceSecondary,
+		consts.ReplicationPerformanceStandby,
+		consts.ReplicationDRSecondary,
+	} {
+		system.ReplicationStateVal = state
+		if err := b.periodicFunc(context.Background(), &logical.Request{Storage: storage}); err != nil {
+			t.Fatal(err)
+		}
+		if cfg, err = getRootConfig(context.Background(), storage); err != nil {
+			t.Fatal(err)
+		}
+		if cfg.PublicKey != oldPublicKey {
+			t.Fatalf("expected no rotation with replication state %d", state)
+		}
+	}
+
+	// Local mounts are not replicated to performance secondaries
+	system.ReplicationStateVal = consts.ReplicationPerformanceSecondary
+	system.LocalMountVal = true
+	if err := b.periodicFunc(context.Background(), &logical.Request{Storage: storage}); err != nil {
+		t.Fatal(err)
+	}
+	if cfg, err = getRootConfig(context.Background(), storage); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.PublicKey == oldPublicKey {
+		t.Fatal("expected the root key of a local mount to be rotated")
+	}
+}
+
+func TestRotationBackoff(t *testing.T) {
+	var backoff rotationBackoff
+	now := time.Now()
+
+	for _, expected := range []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute} {
+		if delay := backoff.failed(now); delay != expected {
+			t.Fatalf("expected a delay of %s, got %s", expected, delay)
+		}
+	}
+	for i := 0; i < 10; i++ {
+		backoff.failed(now)
+	}
+	if delay := backoff.failed(now); delay != maxRotationBackoff {
+		t.Fatalf("expected the delay to be capped at %s, got %s", maxRotationBackoff, delay)
+	}
+	if !backoff.next.Equal(now.Add(maxRotationBackoff)) {
+		t.Fatalf("expected the next attempt at %s, got %s", now.Add(maxRotationBackoff), backoff.next)
+	}
+
+	backoff.reset()
+	if backoff.failures != 0 || !backoff.next.IsZero() {
+		t.Fatalf("expected the backoff to be reset, got %+v", backoff)
+	}
+}
@@ -2,6 +2,7 @@ package mongodbatlas
 
 import (
 	"context"
+	"net/http"
 	"testing"
 
 	"github.com/go-test/deep"
@@ -21,8 +22,9 @@ func TestBackend_PathConfig(t *testing.T) {
 
 	// Test write operation
 	configData := map[string]interface{}{
-		"public_key":  "my_public_key",
-		"private_key": "my_private_key",
+		"public_key":        "my_public_key",
+		"private_key":       "my_private_key",
+		"verify_connection": false,
 	}
 
 	resp, err = b.HandleRequest(context.Background(), &logical.Request{
@@ -48,19 +50,104 @@ func TestBackend_PathConfig(t *testing.T) {
 		t.Fatalf("config write failed:. resp:%#v err:%v", resp, err)
 	}
 
+	if lastRotated, ok := resp.Data["last_rotated"].(string); !ok || lastRotated == "" {
+		t.Fatalf("expected last_rotated to be set, got: %v", resp.Data["last_rotated"])
+	}
+	delete(resp.Data, "last_rotated")
+
 	expected := map[string]interface{}{
-		"public_key": "my_public_key",
+		"public_key":               "my_public_key",
+		"organization_id":          "",
+		"base_url":                 "",
+		"rotation_period":          int64(0),
+		"tidy_interval":            int64(0),
+		"allowed_org_roles":        []string(nil),
+		"allowed_project_roles":    []string(nil),
+		"allowed_organization_ids": []string(nil),
+		"allowed_project_ids":      []string(nil),
 	}
 
 	if diff := deep.Equal(expected, resp.Data); diff != nil {
 		t.Fatalf("bad response. expected %v, got: %v", expected, resp.Data)
 	}
 
+	// Test updating the rotation period without the keys
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config",
+		Data: map[string]interface{}{
+			"rotation_period":   "2160h",
+			"verify_connection": false,
+		},
+		Storage: config.StorageView,
+	})
+
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("config write failed:. resp:%#v err:%v", resp, err)
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "config",
+		Storage:   config.StorageView,
+	})
+
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("config read failed:. resp:%#v err:%v", resp, err)
+	}
+
+	if resp.Data["public_key"] != "my_public_key" || resp.Data["rotation_period"] != int64(7776000) {
+		t.Fatalf("bad response. got: %v", resp.Data)
+	}
+
+	// Test setting the base URL
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config",
+		Data: map[string]interface{}{
+			"base_url":          "https://opsmanager.example.com/api/public/v1.0",
+			"verify_connection": false,
+		},
+		Storage: config.StorageView,
+	})
+
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("config write failed:. resp:%#v err:%v", resp, err)
+	}
+
+	cfg, err := getRootConfig(context.Background(), config.StorageView)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.BaseURL != "https://opsmanager.example.com/api/public/v1.0/" {
+		t.Fatalf("bad base_url: %q", cfg.BaseURL)
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config",
+		Data: map[string]interface{}{
+			"base_url":          "opsmanager.example.com",
+			"verify_connection": false,
+		},
+		Storage: config.StorageView,
+	})
+
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected error response for an invalid base_url. resp:%#v err:%v", resp, err)
+	}
+
+	// The rotation period has not elapsed, so nothing is rotated
+	if err := b.rotateRootIfDue(context.Background(), config.StorageView); err != nil {
+		t.Fatal(err)
+	}
+
 	// Test bad data on write
 
 	// Missing public key
 	configData = map[string]interface{}{
-		"private_key": "my_private_key",
+		"private_key":       "my_private_key",
+		"verify_connection": false,
 	}
 
 	resp, err = b.HandleRequest(context.Background(), &logical.Request{
@@ -76,7 +163,8 @@ func TestBackend_PathConfig(t *testing.T) {
 
 	// Missing private key
 	configData = map[string]interface{}{
-		"public_key": "my_public_key",
+		"public_key":        "my_public_key",
+		"verify_connection": false,
 	}
 
 	resp, err = b.HandleRequest(context.Background(), &logical.Request{
@@ -90,3 +178,50 @@ func TestBackend_PathConfig(t *testing.T) {
 		t.Fatal("expect error response but got nil")
 	}
 }
+
+func TestBackend_PathConfig_VerifyConnection(t *testing.T) {
+	b, storage, atlas := newFakeAtlasBackend(t)
+	defer atlas.Close()
+
+	root := atlas.key(atlas.rootKey)
+	configData := map[string]interface{}{
+		"public_key":  root.PublicKey,
+		"private_key": root.PrivateKey,
+		"base_url":    atlas.URL(),
+	}
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config",
+		Data:      configData,
+		Storage:   storage,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("config write failed:. resp:%#v err:%v", resp, err)
+	}
+	if resp == nil || resp.Data["organization_id"] != fakeOrganizationID {
+		t.Fatalf("expected the organization of the keys to be reported. resp:%#v", resp)
+	}
+
+	// Invalid credentials are not stored
+	atlas.fail(http.MethodGet, "root", http.StatusUnauthorized)
+	configData["public_key"] = "my_public_key"
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config",
+		Data:      configData,
+		Storage:   storage,
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected error response. resp:%#v err:%v", resp, err)
+	}
+
+	cfg, err := getRootConfig(context.Background(), storage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.PublicKey != root.PublicKey {
+		t.Fatal("expected the verified credentials to remain stored")
+	}
+}
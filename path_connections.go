@@ -0,0 +1,345 @@
+package mongodbatlas
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// connectionPath is the storage prefix of the named connections, which hold
+// the credentials of additional MongoDB Atlas organizations. They are served
+// under the same prefix rather than "config/", where their names would
+// collide with "config/lease" and "config/rotate-root".
+const connectionPath = "connections/"
+
+func (b *Backend) pathConnectionsList() *framework.Path {
+	return &framework.Path{
+		Pattern: "connections/?$",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ListOperation: b.operationListConnections,
+		},
+
+		HelpSynopsis:    pathConnectionsListHelpSyn,
+		HelpDescription: pathConnectionsListHelpDesc,
+	}
+}
+
+func (b *Backend) pathConnections() *framework.Path {
+	return &framework.Path{
+		Pattern: "connections/" + framework.GenericNameRegex("connection_name"),
+		Fields: map[string]*framework.FieldSchema{
+			"connection_name": {
+				Type:        framework.TypeLowerCaseString,
+				Description: "Name of the connection",
+				Required:    true,
+			},
+			"public_key": {
+				Type:        framework.TypeString,
+				Description: "MongoDB Atlas Programmatic Public Key",
+				Required:    true,
+			},
+			"private_key": {
+				Type:        framework.TypeString,
+				Description: "MongoDB Atlas Programmatic Private Key",
+				Required:    true,
+				DisplayAttrs: &framework.DisplayAttributes{
+					Sensitive: true,
+				},
+			},
+			"base_url": {
+				Type:        framework.TypeString,
+				Description: "Base URL of the MongoDB Atlas API, used to reach Ops Manager or Cloud Manager. Defaults to the MongoDB Atlas API.",
+			},
+			"verify_connection": {
+				Type:        framework.TypeBool,
+				Default:     true,
+				Description: "If true, the credentials are verified against the MongoDB Atlas API before they are stored. Defaults to true.",
+			},
+			"allowed_org_roles": {
+				Type:        framework.TypeCommaStringSlice,
+				Description: "Organization roles that roles using the connection may grant. Defaults to all of them.",
+			},
+			"allowed_project_roles": {
+				Type:        framework.TypeCommaStringSlice,
+				Description: "Project roles that roles using the connection may grant. Defaults to all of them.",
+			},
+			"allowed_organization_ids": {
+				Type:        framework.TypeCommaStringSlice,
+				Description: "Organizations that roles using the connection may issue credentials in. Defaults to all of them.",
+			},
+			"allowed_project_ids": {
+				Type:        framework.TypeCommaStringSlice,
+				Description: "Projects that roles using the connection may issue credentials in. Defaults to all of them.",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathConnectionsRead,
+			logical.UpdateOperation: b.pathConnectionsWrite,
+			logical.DeleteOperation: b.pathConnectionsDelete,
+		},
+
+		HelpSynopsis:    pathConnectionsHelpSyn,
+		HelpDescription: pathConnectionsHelpDesc,
+	}
+}
+
+func (b *Backend) operationListConnections(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	names, err := req.Storage.List(ctx, connectionPath)
+	if err != nil {
+		return nil, err
+	}
+	return logical.ListResponse(names), nil
+}
+
+func (b *Backend) pathConnectionsRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	cfg, err := readConnectionConfig(ctx, req.Storage, d.Get("connection_name").(string))
+	if err != nil {
+		return nil, err
+	}
+	if cfg == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"public_key":               cfg.PublicKey,
+			"organization_id":          cfg.OrganizationID,
+			"base_url":                 cfg.BaseURL,
+			"allowed_org_roles":        cfg.AllowedOrgRoles,
+			"allowed_project_roles":    cfg.AllowedProjectRoles,
+			"allowed_organization_ids": cfg.AllowedOrganizationIDs,
+			"allowed_project_ids":      cfg.AllowedProjectIDs,
+		},
+	}, nil
+}
+
+func (b *Backend) pathConnectionsWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("connection_name").(string)
+
+	cfg, err := readConnectionConfig(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+
+	_, publicKeyOk := data.GetOk("public_key")
+	_, privateKeyOk := data.GetOk("private_key")
+	if cfg == nil || publicKeyOk || privateKeyOk {
+		publicKey := data.Get("public_key").(string)
+		if publicKey == "" {
+			return logical.ErrorResponse("public_key is empty"), nil
+		}
+
+		privateKey := data.Get("private_key").(string)
+		if privateKey == "" {
+			return logical.ErrorResponse("private_key is empty"), nil
+		}
+
+		if cfg == nil {
+			cfg = &config{}
+		}
+		cfg.PublicKey = publicKey
+		cfg.PrivateKey = privateKey
+	}
+
+	if baseURLRaw, ok := data.GetOk("base_url"); ok {
+		baseURL, err := normalizeBaseURL(baseURLRaw.(string))
+		if err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+		cfg.BaseURL = baseURL
+	}
+
+	updateAllowLists(cfg, data)
+
+	var resp *logical.Response
+	if data.Get("verify_connection").(bool) {
+		orgID, err := verifyRootConfig(ctx, cfg)
+		if err != nil {
+			return logical.ErrorResponse("error verifying the MongoDB Atlas credentials: %s", err), nil
+		}
+		cfg.OrganizationID = orgID
+
+		resp = &logical.Response{
+			Data: map[string]interface{}{
+				"organization_id": orgID,
+			},
+		}
+	}
+
+	entry, err := logical.StorageEntryJSON(connectionPath+name, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(ctx, entry); err != nil {
+		return nil, err
+	}
+
+	b.resetClient(name)
+
+	return resp, nil
+}
+
+func (b *Backend) pathConnectionsDelete(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("connection_name").(string)
+
+	// The leases of the roles would otherwise be left without credentials
+	// to revoke them with
+	roles, err := connectionRoles(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(roles) > 0 {
+		return logical.ErrorResponse("connection %q is used by roles %s", name, strings.Join(roles, ", ")), nil
+	}
+
+	if err := req.Storage.Delete(ctx, connectionPath+name); err != nil {
+		return nil, err
+	}
+
+	b.resetClient(name)
+
+	return nil, nil
+}
+
+// connectionRoles returns the names of the roles using a connection.
+func connectionRoles(ctx context.Context, s logical.Storage, name string) ([]string, error) {
+	roleNames, err := listRoleNames(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+
+	var roles []string
+	for _, roleName := range roleNames {
+		entry, err := s.Get(ctx, "roles/"+roleName)
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			continue
+		}
+		var cred atlasCredentialEntry
+		if err := entry.DecodeJSON(&cred); err != nil {
+			return nil, err
+		}
+		if cred.Connection == name {
+			roles = append(roles, roleName)
+		}
+	}
+	sort.Strings(roles)
+	return roles, nil
+}
+
+// connectionNames returns the names of all the connections, starting with
+// the empty name of the root configuration if it is stored.
+func connectionNames(ctx context.Context, s logical.Storage) ([]string, error) {
+	names, err := s.List(ctx, connectionPath)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := readRootConfig(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	if cfg == nil {
+		return names, nil
+	}
+	return append([]string{""}, names...), nil
+}
+
+// connectionPublicKeys returns the public keys of the root configuration, if
+// any, and of all the named connections.
+func connectionPublicKeys(ctx context.Context, s logical.Storage) (map[string]bool, error) {
+	publicKeys := map[string]bool{}
+
+	cfg, err := readRootConfig(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	if cfg != nil {
+		publicKeys[cfg.PublicKey] = true
+	}
+
+	names, err := s.List(ctx, connectionPath)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		cfg, err := readConnectionConfig(ctx, s, name)
+		if err != nil {
+			return nil, err
+		}
+		if cfg != nil {
+			publicKeys[cfg.PublicKey] = true
+		}
+	}
+	return publicKeys, nil
+}
+
+// getConnectionConfig returns the configuration of a named connection, or the
+// root configuration if the name is empty.
+func getConnectionConfig(ctx context.Context, s logical.Storage, name string) (*config, error) {
+	if name == "" {
+		return getRootConfig(ctx, s)
+	}
+
+	cfg, err := readConnectionConfig(ctx, s, name)
+	if err != nil {
+		return nil, err
+	}
+	if cfg == nil {
+		return nil, fmt.Errorf("connection %q does not exist", name)
+	}
+	return cfg, nil
+}
+
+func readConnectionConfig(ctx context.Context, s logical.Storage, name string) (*config, error) {
+	entry, err := s.Get(ctx, connectionPath+name)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var cfg config
+	if err := entry.DecodeJSON(&cfg); err != nil {
+		return nil, errwrap.Wrapf(fmt.Sprintf("error reading connection %q: {{err}}", name), err)
+	}
+	return &cfg, nil
+}
+
+const pathConnectionsListHelpSyn = `List the named connections to MongoDB Atlas.`
+const pathConnectionsListHelpDesc = `List the names of the connections configured with "connections/<connection_name>".`
+
+const pathConnectionsHelpSyn = `
+Configure a named connection to MongoDB Atlas.
+`
+const pathConnectionsHelpDesc = `
+Named connections hold the Programmatic API Keys of additional MongoDB Atlas
+organizations, so that one mount can issue credentials in all of them. Roles
+use a connection by setting their "connection" parameter; roles without one
+use the credentials of the "config" endpoint.
+
+Connections are configured under "connections/" rather than "config/", so
+that their names can't collide with "config/lease" or "config/rotate-root".
+
+Unless "verify_connection" is false, the credentials are verified against
+the MongoDB Atlas API before they are stored, and the organization they
+belong to is returned.
+
+"allowed_org_roles", "allowed_project_roles", "allowed_organization_ids"
+and "allowed_project_ids" restrict the roles using the connection, like the
+allow-lists of the "config" endpoint restrict the roles using it.
+
+A connection can't be deleted while roles use it. The credentials of named
+connections are not rotated by the backend, and static roles always use the
+credentials of the "config" endpoint.
+`
@@ -0,0 +1,319 @@
+package mongodbatlas
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/mongodb/go-client-mongodb-atlas/mongodbatlas"
+)
+
+// newOtherFakeAtlas returns a second fake Atlas API standing in for another
+// organization, whose key IDs and public keys don't collide with the first.
+func newOtherFakeAtlas() *fakeAtlas {
+	other := newFakeAtlas()
+
+	other.Lock()
+	defer other.Unlock()
+	delete(other.keys, other.rootKey)
+	other.lastID = 100
+	other.rootKey = other.addKey(fakeOrganizationID, "root key", []mongodbatlas.APIKeyRole{
+		{OrgID: fakeOrganizationID, RoleName: "ORG_OWNER"},
+	}).ID
+
+	return other
+}
+
+func TestBackend_PathConnections(t *testing.T) {
+	b, storage, atlas := newFakeAtlasBackend(t)
+	defer atlas.Close()
+
+	other := newOtherFakeAtlas()
+	defer other.Close()
+	otherRoot := other.key(other.rootKey)
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "connections/other",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"public_key":  otherRoot.PublicKey,
+			"private_key": otherRoot.PrivateKey,
+			"base_url":    other.URL(),
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: connection write failed:. resp:%#v err:%v", resp, err)
+	}
+	if resp.Data["organization_id"] != fakeOrganizationID {
+		t.Fatalf("expected the organization of the connection, got %v", resp.Data)
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ListOperation,
+		Path:      "connections/",
+		Storage:   storage,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: connection list failed:. resp:%#v err:%v", resp, err)
+	}
+	if !reflect.DeepEqual(resp.Data["keys"], []string{"other"}) {
+		t.Fatalf("expected connection other to be listed, got %v", resp.Data["keys"])
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "connections/other",
+		Storage:   storage,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: connection read failed:. resp:%#v err:%v", resp, err)
+	}
+	if resp.Data["public_key"] != otherRoot.PublicKey || resp.Data["private_key"] != nil {
+		t.Fatalf("unexpected connection %v", resp.Data)
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      "roles/missing-connection",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"organization_id": fakeOrganizationID,
+			"roles":           []string{"ORG_MEMBER"},
+			"connection":      "missing",
+		},
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected a role with an unknown connection to be rejected, got resp:%#v err:%v", resp, err)
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      "roles/other-key",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"organization_id": fakeOrganizationID,
+			"roles":           []string{"ORG_MEMBER"},
+			"connection":      "other",
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: role creation failed:. resp:%#v err:%v", resp, err)
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "creds/other-key",
+		Storage:   storage,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: reading credentials failed:. resp:%#v err:%v", resp, err)
+	}
+	keyID := resp.Secret.InternalData["programmatic_api_key_id"].(string)
+	if other.key(keyID) == nil || atlas.key(keyID) != nil {
+		t.Fatalf("expected key %q to be created with connection other", keyID)
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.DeleteOperation,
+		Path:      "connections/other",
+		Storage:   storage,
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected deleting a connection in use to be refused, got resp:%#v err:%v", resp, err)
+	}
+
+	// Orphaned keys of the connection are tidied with its credentials
+	mountID, err := b.getMountID(context.Background(), storage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other.Lock()
+	orphan := other.addKey(fakeOrganizationID, "vault-other-key-"+mountID+"-aaaaaaaaaaaaaaaaaaaa", nil)
+	other.Unlock()
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "tidy/keys",
+		Storage:   storage,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: tidy failed:. resp:%#v err:%v", resp, err)
+	}
+	if other.key(orphan.ID) != nil {
+		t.Fatalf("expected orphaned key %q of connection other to be deleted", orphan.ID)
+	}
+	if other.key(keyID) == nil || other.key(other.rootKey) == nil {
+		t.Fatal("expected the leased key and the connection key to remain")
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/other-key/revoke-all",
+		Storage:   storage,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: revoke-all failed:. resp:%#v err:%v", resp, err)
+	}
+	if other.key(keyID) != nil {
+		t.Fatalf("expected key %q to be revoked with connection other", keyID)
+	}
+
+	for _, req := range []*logical.Request{
+		{Operation: logical.DeleteOperation, Path: "roles/other-key"},
+		{Operation: logical.DeleteOperation, Path: "connections/other"},
+	} {
+		req.Storage = storage
+		resp, err = b.HandleRequest(context.Background(), req)
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("bad: %s failed:. resp:%#v err:%v", req.Path, resp, err)
+		}
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "connections/other",
+		Storage:   storage,
+	})
+	if err != nil || resp != nil {
+		t.Fatalf("expected connection other to be deleted, got resp:%#v err:%v", resp, err)
+	}
+}
+
+func TestBackend_PathConnections_AllowLists(t *testing.T) {
+	b, storage, atlas := newFakeAtlasBackend(t)
+	defer atlas.Close()
+
+	// The allow-lists of a connection apply without a root configuration
+	if err := storage.Delete(context.Background(), "config"); err != nil {
+		t.Fatal(err)
+	}
+	b.resetClient("")
+
+	other := newOtherFakeAtlas()
+	defer other.Close()
+	otherRoot := other.key(other.rootKey)
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "connections/other",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"public_key":        otherRoot.PublicKey,
+			"private_key":       otherRoot.PrivateKey,
+			"base_url":          other.URL(),
+			"allowed_org_roles": []string{"ORG_READ_ONLY"},
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: connection write failed:. resp:%#v err:%v", resp, err)
+	}
+
+	writeRole := func(roles ...string) (*logical.Response, error) {
+		return b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.CreateOperation,
+			Path:      "roles/other-key",
+			Storage:   storage,
+			Data: map[string]interface{}{
+				"organization_id": fakeOrganizationID,
+				"roles":           roles,
+				"connection":      "other",
+			},
+		})
+	}
+
+	resp, err = writeRole("ORG_MEMBER")
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected a role outside of the allow-lists of the connection to be rejected, got resp:%#v err:%v", resp, err)
+	}
+	resp, err = writeRole("ORG_READ_ONLY")
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: role creation failed:. resp:%#v err:%v", resp, err)
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "connections/other",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"allowed_organization_ids": []string{"5b71ff2f96e82120d0aaec15"},
+			"verify_connection":        false,
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: connection write failed:. resp:%#v err:%v", resp, err)
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "connections/other",
+		Storage:   storage,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: connection read failed:. resp:%#v err:%v", resp, err)
+	}
+	if !reflect.DeepEqual(resp.Data["allowed_org_roles"], []string{"ORG_READ_ONLY"}) {
+		t.Fatalf("expected the allow-lists of the connection to be kept, got %v", resp.Data)
+	}
+
+	keyCount := other.keyCount()
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "creds/other-key",
+		Storage:   storage,
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected credentials outside of the allow-lists of the connection to be refused, got resp:%#v err:%v", resp, err)
+	}
+	if other.keyCount() != keyCount {
+		t.Fatal("expected no key to be created")
+	}
+}
+
+func TestBackend_PathConnections_WithoutRootConfig(t *testing.T) {
+	b, storage, atlas := newFakeAtlasBackend(t)
+	defer atlas.Close()
+
+	if err := storage.Delete(context.Background(), "config"); err != nil {
+		t.Fatal(err)
+	}
+	b.resetClient("")
+
+	other := newOtherFakeAtlas()
+	defer other.Close()
+	otherRoot := other.key(other.rootKey)
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "connections/other",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"public_key":  otherRoot.PublicKey,
+			"private_key": otherRoot.PrivateKey,
+			"base_url":    other.URL(),
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: connection write failed:. resp:%#v err:%v", resp, err)
+	}
+
+	// Only the named connections are searched
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "tidy/keys",
+		Storage:   storage,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: tidy failed:. resp:%#v err:%v", resp, err)
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "revoke/public-key/unknown",
+		Storage:   storage,
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected an unknown public key to be reported, got resp:%#v err:%v", resp, err)
+	}
+}
@@ -4,11 +4,14 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net"
 	"regexp"
+	"time"
 
 	"github.com/hashicorp/errwrap"
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/helper/base62"
+	"github.com/hashicorp/vault/sdk/helper/strutil"
 	"github.com/hashicorp/vault/sdk/logical"
 )
 
@@ -16,13 +19,29 @@ var displayNameRegex = regexp.MustCompile("[^a-zA-Z0-9+=,.@_-]")
 
 func (b *Backend) pathCredentials() *framework.Path {
 	return &framework.Path{
-		Pattern: "creds/" + framework.GenericNameRegex("name"),
+		Pattern: "creds/" + roleNameRegex("name") + "$",
 		Fields: map[string]*framework.FieldSchema{
 			"name": {
 				Type:        framework.TypeLowerCaseString,
 				Description: "Name of the role",
 				Required:    true,
 			},
+			"ttl": {
+				Type:        framework.TypeDurationSecond,
+				Description: "Duration in seconds after which the issued credential should expire. Capped by the max_ttl of the role.",
+			},
+			"roles": {
+				Type:        framework.TypeCommaStringSlice,
+				Description: "Subset of the roles and project_roles of the role to grant to the issued Programmatic API Key.",
+			},
+			"client_ip": {
+				Type:        framework.TypeString,
+				Description: "IP address to bind the issued Programmatic API Key to instead of the address of the request. Must be within the allowed_cidr_blocks of the role.",
+			},
+			"dry_run": {
+				Type:        framework.TypeBool,
+				Description: "If true, the Programmatic API Key that would be issued is described without being created.",
+			},
 		},
 		Callbacks: map[logical.Operation]framework.OperationFunc{
 			logical.ReadOperation:   b.pathCredentialsRead,
@@ -47,14 +66,152 @@ func (b *Backend) pathCredentialsRead(ctx context.Context, req *logical.Request,
 		return nil, errors.New("error retrieving credential: credential is nil")
 	}
 
-	return b.programmaticAPIKeyCreate(ctx, req.Storage, userName, cred)
+	var warnings []string
+	var requestedTTL time.Duration
+	if ttlRaw, ok := d.GetOk("ttl"); ok {
+		ttl := time.Duration(ttlRaw.(int)) * time.Second
+		if ttl <= 0 {
+			return logical.ErrorResponse("ttl must be greater than 0"), nil
+		}
+
+		_, maxLease, err := b.getCredentialLease(ctx, req.Storage, cred)
+		if err != nil {
+			return nil, err
+		}
+		if maxLease > 0 && ttl > maxLease {
+			warnings = append(warnings, fmt.Sprintf("ttl of %s is greater than the max_ttl of the role, capping it to %s", ttl, maxLease))
+			ttl = maxLease
+		}
+		cred.TTL = ttl
+		requestedTTL = ttl
+	}
+
+	if rolesRaw, ok := d.GetOk("roles"); ok {
+		if cred.CredentialType == databaseUser {
+			return logical.ErrorResponse("roles is only supported by roles with the %q credential type", programmaticAPIKey), nil
+		}
+		if err := narrowCredentialRoles(cred, rolesRaw.([]string)); err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+	}
+
+	if cred.BindClientIP {
+		clientIP, err := requestClientIP(req, cred, d.Get("client_ip").(string))
+		if err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+		cred.IPAddresses = strutil.AppendIfMissing(cred.IPAddresses, clientIP)
+	} else if _, ok := d.GetOk("client_ip"); ok {
+		return logical.ErrorResponse("client_ip is only supported by roles with bind_client_ip set"), nil
+	}
+
+	dryRun := d.Get("dry_run").(bool)
+	if dryRun && cred.CredentialType == databaseUser {
+		return logical.ErrorResponse("dry_run is only supported by roles with the %q credential type", programmaticAPIKey), nil
+	}
+
+	// The allow-lists may have changed since the role was written
+	if errResp, err := b.checkRoleAllowed(ctx, req.Storage, cred); errResp != nil || err != nil {
+		return errResp, err
+	}
 
+	var resp *logical.Response
+	switch {
+	case dryRun:
+		resp, err = b.programmaticAPIKeyPreview(ctx, req, userName, cred)
+	case cred.CredentialType == databaseUser:
+		resp, err = b.databaseUserCreate(ctx, req.Storage, userName, cred)
+	default:
+		resp, err = b.programmaticAPIKeyCreate(ctx, req, userName, cred)
+	}
+	if resp != nil {
+		for _, warning := range warnings {
+			resp.AddWarning(warning)
+		}
+		// The requested ttl also bounds the renewals of the lease
+		if resp.Secret != nil && requestedTTL > 0 {
+			resp.Secret.InternalData["ttl"] = int64(requestedTTL.Seconds())
+		}
+	}
+	return resp, err
+}
+
+// narrowCredentialRoles restricts the roles and project roles of a credential
+// to the requested roles, which must all be granted by the credential.
+func narrowCredentialRoles(cred *atlasCredentialEntry, requested []string) error {
+	var roles, projectRoles []string
+	for _, role := range requested {
+		granted := false
+		if strutil.StrListContains(cred.Roles, role) {
+			roles = strutil.AppendIfMissing(roles, role)
+			granted = true
+		}
+		if strutil.StrListContains(cred.ProjectRoles, role) {
+			projectRoles = strutil.AppendIfMissing(projectRoles, role)
+			granted = true
+		}
+		if !granted {
+			return fmt.Errorf("role %q is not granted by the role", role)
+		}
+	}
+
+	// Keys are created with their roles, and keys assigned to a project
+	// need at least one role in the project as well
+	if len(roles) == 0 {
+		return fmt.Errorf("roles must include at least one of %v", cred.Roles)
+	}
+	if isAssignedToProject(cred.OrganizationID, cred.ProjectID) && len(projectRoles) == 0 {
+		return fmt.Errorf("roles must include at least one of the project roles %v", cred.ProjectRoles)
+	}
+
+	cred.Roles = roles
+	cred.ProjectRoles = projectRoles
+	return nil
+}
+
+// requestClientIP returns the IP address to bind the key of a credential to,
+// which is either the supplied client IP, if it's within the allowed CIDR
+// blocks of the credential, or the address of the request.
+func requestClientIP(req *logical.Request, cred *atlasCredentialEntry, clientIP string) (string, error) {
+	if clientIP != "" {
+		ip := net.ParseIP(clientIP)
+		if ip == nil {
+			return "", fmt.Errorf("invalid client_ip %q", clientIP)
+		}
+		for _, cidrBlock := range cred.AllowedCIDRBlocks {
+			_, allowed, err := net.ParseCIDR(cidrBlock)
+			if err != nil {
+				return "", err
+			}
+			if allowed.Contains(ip) {
+				return ip.String(), nil
+			}
+		}
+		return "", fmt.Errorf("client_ip %q is not within the allowed_cidr_blocks of the role", clientIP)
+	}
+
+	if req.Connection == nil || req.Connection.RemoteAddr == "" {
+		return "", errors.New("bind_client_ip is set but the client IP address of the request is unknown; " +
+			"if Vault is behind a proxy, configure the listener to trust its X-Forwarded-For header, " +
+			"or supply a client_ip within the allowed_cidr_blocks of the role")
+	}
+
+	remoteAddr := req.Connection.RemoteAddr
+	if host, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		remoteAddr = host
+	}
+	ip := net.ParseIP(remoteAddr)
+	if ip == nil {
+		return "", fmt.Errorf("bind_client_ip is set but the client address %q of the request is not an IP address", req.Connection.RemoteAddr)
+	}
+	return ip.String(), nil
 }
 
 type walEntry struct {
 	UserName             string
 	ProjectID            string
 	OrganizationID       string
+	Connection           string
 	ProgrammaticAPIKeyID string
 }
 
@@ -70,6 +227,18 @@ func genUsername(displayName string) (string, error) {
 	return ret, nil
 }
 
+// genAPIKeyDescription generates the description of a Programmatic API Key,
+// which also identifies the mount that issued it.
+func genAPIKeyDescription(displayName, mountID string) (string, error) {
+	midString := displayNameRegex.ReplaceAllString(displayName, "_")
+
+	id, err := base62.Random(20)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("vault-%s-%s-%s", midString, mountID, id), nil
+}
+
 const pathCredentialsHelpSyn = `
 Generate MongoDB Atlas Programmatic API from a specific Vault role.
 `
@@ -78,4 +247,19 @@ This path reads generates MongoDB Atlas Programmatic API Keys for
 a particular role. Atlas Programmatic API Keys will be
 generated on demand and will be automatically revoked when
 the lease is up.
+
+The optional "ttl" requests a shorter lease than the default of the
+role, which also applies when the lease is renewed, and "roles" requests a Programmatic API Key with only a subset
+of the roles and project roles of the role.
+
+Roles with "bind_client_ip" set add the IP address of the request to
+the whitelist of the Programmatic API Key, or the optional "client_ip"
+if it's within the "allowed_cidr_blocks" of the role.
+
+With "dry_run" set, the Programmatic API Key that would be issued is
+described instead, along with the MongoDB Atlas calls that would be made
+and whether the configured key is allowed to make them.
+
+Roles with the "database_user" credential type generate MongoDB
+Atlas Database Users instead, which are deleted when the lease is up.
 `
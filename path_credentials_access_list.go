@@ -0,0 +1,242 @@
+package mongodbatlas
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/mongodb/go-client-mongodb-atlas/mongodbatlas"
+)
+
+func (b *Backend) pathCredentialsAccessList() *framework.Path {
+	return &framework.Path{
+		Pattern: "creds/" + roleNameRegex("name") + "/access-list$",
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeLowerCaseString,
+				Description: "Name of the role",
+				Required:    true,
+			},
+			"lease_id": {
+				Type:        framework.TypeString,
+				Description: "Lease ID of the Programmatic API Key. Only known to the backend once the lease has been renewed.",
+			},
+			"public_key": {
+				Type:        framework.TypeString,
+				Description: "Public key of the Programmatic API Key",
+			},
+			"add": {
+				Type:        framework.TypeCommaStringSlice,
+				Description: "IP addresses or CIDR blocks to add to the whitelist of the key, within the allowed_cidr_blocks of the role.",
+			},
+			"remove": {
+				Type:        framework.TypeCommaStringSlice,
+				Description: "IP addresses or CIDR blocks to remove from the whitelist of the key, within the allowed_cidr_blocks of the role.",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathCredentialsAccessListRead,
+			logical.UpdateOperation: b.pathCredentialsAccessListWrite,
+		},
+
+		HelpSynopsis:    pathCredentialsAccessListHelpSyn,
+		HelpDescription: pathCredentialsAccessListHelpDesc,
+	}
+}
+
+func (b *Backend) pathCredentialsAccessListRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	issued, errResp, err := roleIssuedAPIKey(ctx, req.Storage, d)
+	if errResp != nil || err != nil {
+		return errResp, err
+	}
+
+	client, err := b.connectionClient(ctx, req.Storage, issued.Connection)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	return accessListResponse(ctx, client, issued)
+}
+
+func (b *Backend) pathCredentialsAccessListWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	issued, errResp, err := roleIssuedAPIKey(ctx, req.Storage, d)
+	if errResp != nil || err != nil {
+		return errResp, err
+	}
+
+	cred, err := b.credentialRead(ctx, req.Storage, issued.Role)
+	if err != nil {
+		return nil, errwrap.Wrapf("error retrieving credential: {{err}}", err)
+	}
+	if cred == nil {
+		return logical.ErrorResponse("role %q not found", issued.Role), nil
+	}
+	if len(cred.AllowedCIDRBlocks) == 0 {
+		return logical.ErrorResponse("role %q has no allowed_cidr_blocks to change the whitelist within", issued.Role), nil
+	}
+
+	add := d.Get("add").([]string)
+	remove := d.Get("remove").([]string)
+	if len(add) == 0 && len(remove) == 0 {
+		return logical.ErrorResponse("add or remove is required"), nil
+	}
+
+	var entries []*mongodbatlas.WhitelistAPIKeysReq
+	for _, entry := range add {
+		ipNet, err := accessListEntryWithin(entry, cred.AllowedCIDRBlocks)
+		if err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+		if strings.Contains(entry, "/") {
+			entries = append(entries, &mongodbatlas.WhitelistAPIKeysReq{CidrBlock: ipNet.String()})
+			continue
+		}
+		entries = append(entries, &mongodbatlas.WhitelistAPIKeysReq{IPAddress: ipNet.IP.String()})
+	}
+	for _, entry := range remove {
+		if _, err := accessListEntryWithin(entry, cred.AllowedCIDRBlocks); err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+	}
+
+	client, err := b.connectionClient(ctx, req.Storage, issued.Connection)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	if len(entries) > 0 {
+		if _, _, err := client.WhitelistAPIKeys.Create(ctx, issued.OrganizationID, issued.APIKeyID, entries); err != nil {
+			return nil, errwrap.Wrapf("error adding programmatic API key access list entries: {{err}}", err)
+		}
+	}
+
+	// The entry is part of the request path, and the slash of CIDR blocks
+	// isn't escaped by the client
+	for _, entry := range remove {
+		res, err := client.WhitelistAPIKeys.Delete(ctx, issued.OrganizationID, issued.APIKeyID, url.PathEscape(entry))
+		if err != nil && (res == nil || res.StatusCode != http.StatusNotFound) {
+			return nil, errwrap.Wrapf(fmt.Sprintf("error removing programmatic API key access list entry %q: {{err}}", entry), err)
+		}
+	}
+
+	return accessListResponse(ctx, client, issued)
+}
+
+// roleIssuedAPIKey returns the key issued by the role of the request with
+// the requested lease ID or public key.
+func roleIssuedAPIKey(ctx context.Context, s logical.Storage, d *framework.FieldData) (*issuedAPIKeyEntry, *logical.Response, error) {
+	role := d.Get("name").(string)
+	leaseID := d.Get("lease_id").(string)
+	publicKey := d.Get("public_key").(string)
+
+	var issued *issuedAPIKeyEntry
+	var err error
+	switch {
+	case leaseID != "" && publicKey != "":
+		return nil, logical.ErrorResponse("only one of lease_id or public_key can be provided"), nil
+	case publicKey != "":
+		issued, err = getIssuedAPIKeyByPublicKey(ctx, s, publicKey)
+	case leaseID != "":
+		issued, err = getIssuedAPIKeyByLeaseID(ctx, s, leaseID)
+	default:
+		return nil, logical.ErrorResponse("lease_id or public_key is required"), nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if issued == nil || issued.Role != role {
+		if leaseID != "" {
+			return nil, logical.ErrorResponse("no programmatic API key issued by role %q has lease ID %q; lease IDs are only known once the lease has been renewed, use public_key instead", role, leaseID), nil
+		}
+		return nil, logical.ErrorResponse("no programmatic API key issued by role %q has public key %q", role, publicKey), nil
+	}
+
+	return issued, nil, nil
+}
+
+// accessListEntryWithin parses an IP address or CIDR block, which must be
+// within one of the allowed CIDR blocks.
+func accessListEntryWithin(entry string, allowedCIDRBlocks []string) (*net.IPNet, error) {
+	var ipNet *net.IPNet
+	if strings.Contains(entry, "/") {
+		_, parsed, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR block %q: %s", entry, err)
+		}
+		ipNet = parsed
+	} else {
+		ip := net.ParseIP(entry)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP address %q", entry)
+		}
+		if ip4 := ip.To4(); ip4 != nil {
+			ip = ip4
+		}
+		ipNet = &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)}
+	}
+
+	ones, bits := ipNet.Mask.Size()
+	for _, cidrBlock := range allowedCIDRBlocks {
+		_, allowed, err := net.ParseCIDR(cidrBlock)
+		if err != nil {
+			return nil, err
+		}
+		allowedOnes, allowedBits := allowed.Mask.Size()
+		if bits == allowedBits && ones >= allowedOnes && allowed.Contains(ipNet.IP) {
+			return ipNet, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%q is not within the allowed_cidr_blocks of the role", entry)
+}
+
+func accessListResponse(ctx context.Context, client *mongodbatlas.Client, issued *issuedAPIKeyEntry) (*logical.Response, error) {
+	whitelist, _, err := client.WhitelistAPIKeys.List(ctx, issued.OrganizationID, issued.APIKeyID)
+	if err != nil {
+		return nil, errwrap.Wrapf("error reading programmatic API key access list: {{err}}", err)
+	}
+
+	accessList := []string{}
+	for _, entry := range whitelist.Results {
+		if entry.CidrBlock != "" {
+			accessList = append(accessList, entry.CidrBlock)
+			continue
+		}
+		accessList = append(accessList, entry.IPAddress)
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"programmatic_api_key_id": issued.APIKeyID,
+			"public_key":              issued.PublicKey,
+			"access_list":             accessList,
+		},
+	}, nil
+}
+
+const pathCredentialsAccessListHelpSyn = `
+Manage the whitelist of a MongoDB Atlas Programmatic API Key issued by a role.
+`
+const pathCredentialsAccessListHelpDesc = `
+This path reads and changes the whitelist of a Programmatic API Key that is
+still leased, without revoking and reissuing it. The key is identified by its
+"public_key" or by its "lease_id".
+
+Vault assigns the lease ID after the key is issued, so the backend only
+learns it, and indexes it, when the lease is first renewed. A key whose lease
+has never been renewed can't be found by "lease_id"; use its "public_key",
+which is always known, instead.
+
+Writing "add" adds IP addresses or CIDR blocks to the whitelist of the key,
+and "remove" removes them. Both must be within the "allowed_cidr_blocks" of
+the role, so the entries set by "ip_addresses" and "cidr_blocks" can't be
+removed unless they are.
+`
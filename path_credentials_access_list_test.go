@@ -0,0 +1,129 @@
+package mongodbatlas
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-test/deep"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func TestBackend_PathCredentialsAccessList(t *testing.T) {
+	b, storage, atlas := newFakeAtlasBackend(t)
+	defer atlas.Close()
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      "roles/test-programmatic-key",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"organization_id":     fakeOrganizationID,
+			"roles":               []string{"ORG_MEMBER"},
+			"ip_addresses":        []string{"192.168.1.1"},
+			"allowed_cidr_blocks": []string{"10.0.0.0/8"},
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: role creation failed:. resp:%#v err:%v", resp, err)
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "creds/test-programmatic-key",
+		Storage:   storage,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: reading credentials failed:. resp:%#v err:%v", resp, err)
+	}
+	secret := resp.Secret
+	publicKey := resp.Data["public_key"].(string)
+
+	accessList := func(data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "creds/test-programmatic-key/access-list",
+			Storage:   storage,
+			Data:      data,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	resp = accessList(map[string]interface{}{
+		"public_key": publicKey,
+		"add":        "10.1.2.3,10.2.0.0/16",
+	})
+	if resp.IsError() {
+		t.Fatalf("bad: adding entries failed: %#v", resp)
+	}
+	if diff := deep.Equal([]string{"192.168.1.1/32", "10.1.2.3/32", "10.2.0.0/16"}, resp.Data["access_list"]); diff != nil {
+		t.Fatal(diff)
+	}
+
+	// CIDR blocks are escaped in the request path
+	resp = accessList(map[string]interface{}{
+		"public_key": publicKey,
+		"remove":     "10.2.0.0/16",
+	})
+	if resp.IsError() {
+		t.Fatalf("bad: removing entries failed: %#v", resp)
+	}
+	if diff := deep.Equal([]string{"192.168.1.1/32", "10.1.2.3/32"}, resp.Data["access_list"]); diff != nil {
+		t.Fatal(diff)
+	}
+
+	for name, data := range map[string]map[string]interface{}{
+		"add outside allowed":    {"public_key": publicKey, "add": "172.16.0.1"},
+		"larger block":           {"public_key": publicKey, "add": "10.0.0.0/7"},
+		"remove outside allowed": {"public_key": publicKey, "remove": "192.168.1.1"},
+		"no changes":             {"public_key": publicKey},
+		"unknown key":            {"public_key": "unknown", "add": "10.1.2.4"},
+		"lease not renewed":      {"lease_id": "mongodbatlas/creds/test-programmatic-key/abcd", "add": "10.1.2.4"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if resp := accessList(data); !resp.IsError() {
+				t.Fatalf("expected error response, got %#v", resp)
+			}
+		})
+	}
+
+	// Lease IDs are known once the lease has been renewed
+	secret.LeaseID = "mongodbatlas/creds/test-programmatic-key/abcd"
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.RenewOperation,
+		Storage:   storage,
+		Secret:    secret,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: renewing credentials failed:. resp:%#v err:%v", resp, err)
+	}
+
+	resp = accessList(map[string]interface{}{
+		"lease_id": secret.LeaseID,
+		"remove":   "10.1.2.3",
+	})
+	if resp.IsError() {
+		t.Fatalf("bad: removing entries failed: %#v", resp)
+	}
+
+	key := atlas.key(secret.InternalData["programmatic_api_key_id"].(string))
+	if len(key.accessList) != 1 || key.accessList[0].IPAddress != "192.168.1.1" {
+		t.Fatalf("bad: unexpected access list %v", key.accessList)
+	}
+
+	// The lease ID is no longer indexed once the key is revoked
+	if err := deleteIssuedAPIKey(context.Background(), storage, key.ID); err != nil {
+		t.Fatal(err)
+	}
+	issued, err := getIssuedAPIKeyByLeaseID(context.Background(), storage, secret.LeaseID)
+	if err != nil || issued != nil {
+		t.Fatalf("expected the lease ID not to be found, got %#v err:%v", issued, err)
+	}
+	leaseIDs, err := storage.List(context.Background(), issuedLeaseIDPath)
+	if err != nil || len(leaseIDs) != 0 {
+		t.Fatalf("expected no indexed lease IDs, got %v err:%v", leaseIDs, err)
+	}
+}
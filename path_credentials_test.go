@@ -0,0 +1,318 @@
+package mongodbatlas
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func TestBackend_PathCredentials(t *testing.T) {
+	b, storage, atlas := newFakeAtlasBackend(t)
+	defer atlas.Close()
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      "roles/test-programmatic-key",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"organization_id": fakeOrganizationID,
+			"roles":           []string{"ORG_MEMBER"},
+			"ip_addresses":    []string{"192.168.1.1"},
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: role creation failed:. resp:%#v err:%v", resp, err)
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "creds/test-programmatic-key",
+		Storage:   storage,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: reading credentials failed:. resp:%#v err:%v", resp, err)
+	}
+
+	keyID := resp.Secret.InternalData["programmatic_api_key_id"].(string)
+	key := atlas.key(keyID)
+	if key == nil {
+		t.Fatal("expected the programmatic API key to be created")
+	}
+	if resp.Data["public_key"] != key.PublicKey || resp.Data["private_key"] != key.PrivateKey {
+		t.Fatalf("bad: unexpected credentials %v", resp.Data)
+	}
+	if len(key.Roles) != 1 || key.Roles[0].RoleName != "ORG_MEMBER" {
+		t.Fatalf("bad: unexpected roles %v", key.Roles)
+	}
+	if len(key.accessList) != 1 || key.accessList[0].IPAddress != "192.168.1.1" {
+		t.Fatalf("bad: unexpected access list %v", key.accessList)
+	}
+}
+
+func TestBackend_PathCredentials_Overrides(t *testing.T) {
+	b, storage, atlas := newFakeAtlasBackend(t)
+	defer atlas.Close()
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      "roles/test-programmatic-key",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"organization_id": fakeOrganizationID,
+			"project_id":      fakeProjectID,
+			"roles":           []string{"ORG_MEMBER", "ORG_READ_ONLY"},
+			"project_roles":   []string{"GROUP_OWNER", "GROUP_READ_ONLY"},
+			"ttl":             600,
+			"max_ttl":         3600,
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: role creation failed:. resp:%#v err:%v", resp, err)
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "creds/test-programmatic-key",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"ttl":   7200,
+			"roles": "ORG_READ_ONLY,GROUP_READ_ONLY",
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: reading credentials failed:. resp:%#v err:%v", resp, err)
+	}
+
+	if resp.Secret.TTL != time.Hour || len(resp.Warnings) != 1 {
+		t.Fatalf("expected the ttl to be capped by max_ttl, got %s", resp.Secret.TTL)
+	}
+
+	key := atlas.key(resp.Secret.InternalData["programmatic_api_key_id"].(string))
+	var roles []string
+	for _, role := range key.Roles {
+		roles = append(roles, role.RoleName)
+	}
+	if diff := deep.Equal([]string{"ORG_READ_ONLY", "GROUP_READ_ONLY"}, roles); diff != nil {
+		t.Fatal(diff)
+	}
+
+	// The requested ttl is kept when the lease is renewed
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "creds/test-programmatic-key",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"ttl": 60,
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: reading credentials failed:. resp:%#v err:%v", resp, err)
+	}
+	secret := resp.Secret
+	secret.IssueTime = time.Now()
+	for _, increment := range []time.Duration{0, time.Hour} {
+		secret.Increment = increment
+		renewResp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.RenewOperation,
+			Storage:   storage,
+			Secret:    secret,
+		})
+		if err != nil || (renewResp != nil && renewResp.IsError()) {
+			t.Fatalf("bad: renew failed:. resp:%#v err:%v", renewResp, err)
+		}
+		if renewResp.Secret.TTL != time.Minute {
+			t.Fatalf("expected the requested ttl to be kept with increment %s, got %s", increment, renewResp.Secret.TTL)
+		}
+	}
+
+	for name, roles := range map[string]string{
+		"role not granted":  "ORG_OWNER",
+		"no org role":       "GROUP_READ_ONLY",
+		"no project role":   "ORG_MEMBER",
+		"org role mismatch": "ORG_MEMBER,GROUP_DATA_ACCESS_ADMIN",
+	} {
+		t.Run(name, func(t *testing.T) {
+			resp, err := b.HandleRequest(context.Background(), &logical.Request{
+				Operation: logical.ReadOperation,
+				Path:      "creds/test-programmatic-key",
+				Storage:   storage,
+				Data: map[string]interface{}{
+					"roles": roles,
+				},
+			})
+			if err != nil || resp == nil || !resp.IsError() {
+				t.Fatalf("expected error response. resp:%#v err:%v", resp, err)
+			}
+		})
+	}
+}
+
+func TestBackend_PathCredentials_BindClientIP(t *testing.T) {
+	b, storage, atlas := newFakeAtlasBackend(t)
+	defer atlas.Close()
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      "roles/test-programmatic-key",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"organization_id":     fakeOrganizationID,
+			"roles":               []string{"ORG_MEMBER"},
+			"bind_client_ip":      true,
+			"allowed_cidr_blocks": []string{"10.0.0.0/8"},
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: role creation failed:. resp:%#v err:%v", resp, err)
+	}
+
+	for name, tc := range map[string]struct {
+		connection *logical.Connection
+		clientIP   string
+		expected   string
+	}{
+		"request address":   {connection: &logical.Connection{RemoteAddr: "192.168.1.5"}, expected: "192.168.1.5"},
+		"supplied address":  {connection: &logical.Connection{RemoteAddr: "192.168.1.5"}, clientIP: "10.1.2.3", expected: "10.1.2.3"},
+		"not allowed":       {connection: &logical.Connection{RemoteAddr: "192.168.1.5"}, clientIP: "172.16.0.1"},
+		"unknown address":   {},
+		"invalid client ip": {clientIP: "10.1.2"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			data := map[string]interface{}{}
+			if tc.clientIP != "" {
+				data["client_ip"] = tc.clientIP
+			}
+			resp, err := b.HandleRequest(context.Background(), &logical.Request{
+				Operation:  logical.ReadOperation,
+				Path:       "creds/test-programmatic-key",
+				Storage:    storage,
+				Connection: tc.connection,
+				Data:       data,
+			})
+			if tc.expected == "" {
+				if err != nil || resp == nil || !resp.IsError() {
+					t.Fatalf("expected error response. resp:%#v err:%v", resp, err)
+				}
+				return
+			}
+			if err != nil || (resp != nil && resp.IsError()) {
+				t.Fatalf("bad: reading credentials failed:. resp:%#v err:%v", resp, err)
+			}
+
+			key := atlas.key(resp.Secret.InternalData["programmatic_api_key_id"].(string))
+			if len(key.accessList) != 1 || key.accessList[0].IPAddress != tc.expected {
+				t.Fatalf("bad: unexpected access list %v", key.accessList)
+			}
+		})
+	}
+
+	// The whitelist of project keys is managed in their organization
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      "roles/test-project-key",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"project_id":     fakeProjectID,
+			"roles":          []string{"GROUP_READ_ONLY"},
+			"bind_client_ip": true,
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: role creation failed:. resp:%#v err:%v", resp, err)
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation:  logical.ReadOperation,
+		Path:       "creds/test-project-key",
+		Storage:    storage,
+		Connection: &logical.Connection{RemoteAddr: "192.168.1.5"},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: reading credentials failed:. resp:%#v err:%v", resp, err)
+	}
+
+	key := atlas.key(resp.Secret.InternalData["programmatic_api_key_id"].(string))
+	if len(key.accessList) != 1 || key.accessList[0].IPAddress != "192.168.1.5" {
+		t.Fatalf("bad: unexpected access list %v", key.accessList)
+	}
+}
+
+func TestBackend_PathCredentials_DryRun(t *testing.T) {
+	b, storage, atlas := newFakeAtlasBackend(t)
+	defer atlas.Close()
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      "roles/test-programmatic-key",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"organization_id": fakeOrganizationID,
+			"project_id":      fakeProjectID,
+			"roles":           []string{"ORG_MEMBER"},
+			"project_roles":   []string{"GROUP_READ_ONLY"},
+			"ip_addresses":    []string{"192.168.1.1"},
+			"ttl":             600,
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: role creation failed:. resp:%#v err:%v", resp, err)
+	}
+
+	dryRun := func() *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "creds/test-programmatic-key",
+			Storage:   storage,
+			Data: map[string]interface{}{
+				"dry_run": true,
+			},
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("bad: dry run failed:. resp:%#v err:%v", resp, err)
+		}
+		return resp
+	}
+
+	resp = dryRun()
+	if resp.Secret != nil || atlas.keyCount() != 1 {
+		t.Fatalf("expected a dry run not to create a key, got %d keys", atlas.keyCount())
+	}
+	if !issuedDescriptionRegex.MatchString(resp.Data["description"].(string)) {
+		t.Fatalf("bad: unexpected description %q", resp.Data["description"])
+	}
+	delete(resp.Data, "description")
+
+	expected := map[string]interface{}{
+		"key_type":        "organization_assigned_to_project",
+		"organization_id": fakeOrganizationID,
+		"project_id":      fakeProjectID,
+		"roles":           []string{"ORG_MEMBER"},
+		"project_roles":   []string{"GROUP_READ_ONLY"},
+		"access_list":     []string{"192.168.1.1"},
+		"ttl":             int64(600),
+		"max_ttl":         int64(b.System().MaxLeaseTTL().Seconds()),
+		"calls": []string{
+			"POST orgs/" + fakeOrganizationID + "/apiKeys",
+			"POST orgs/" + fakeOrganizationID + "/apiKeys/<programmatic_api_key_id>/whitelist",
+			"PATCH groups/" + fakeProjectID + "/apiKeys/<programmatic_api_key_id>",
+		},
+		"root_allowed": true,
+	}
+	if diff := deep.Equal(expected, resp.Data); diff != nil {
+		t.Fatal(diff)
+	}
+
+	// The configured key can't create organization keys without ORG_OWNER
+	atlas.Lock()
+	atlas.keys[atlas.rootKey].Roles[0].RoleName = "ORG_READ_ONLY"
+	atlas.Unlock()
+
+	resp = dryRun()
+	if resp.Data["root_allowed"].(bool) || len(resp.Warnings) != 3 {
+		t.Fatalf("expected the configured key not to be allowed, got %#v", resp)
+	}
+}
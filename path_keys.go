@@ -0,0 +1,123 @@
+package mongodbatlas
+
+import (
+	"context"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func (b *Backend) pathKeysList() *framework.Path {
+	return &framework.Path{
+		Pattern: "keys/?$",
+		Fields: map[string]*framework.FieldSchema{
+			"role": {
+				Type:        framework.TypeString,
+				Description: "Only list the keys issued by this role.",
+			},
+			"project_id": {
+				Type:        framework.TypeString,
+				Description: "Only list the keys with access to this project.",
+			},
+			"organization_id": {
+				Type:        framework.TypeString,
+				Description: "Only list the keys of this organization.",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ListOperation: b.operationListKeys,
+		},
+
+		HelpSynopsis:    pathKeysListHelpSyn,
+		HelpDescription: pathKeysListHelpDesc,
+	}
+}
+
+func (b *Backend) pathKeys() *framework.Path {
+	return &framework.Path{
+		Pattern: "keys/" + framework.GenericNameRegex("programmatic_api_key_id"),
+		Fields: map[string]*framework.FieldSchema{
+			"programmatic_api_key_id": {
+				Type:        framework.TypeString,
+				Description: "ID of the Programmatic API Key",
+				Required:    true,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathKeysRead,
+		},
+
+		HelpSynopsis:    pathKeysHelpSyn,
+		HelpDescription: pathKeysHelpDesc,
+	}
+}
+
+func (b *Backend) operationListKeys(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	role := d.Get("role").(string)
+	projectID := d.Get("project_id").(string)
+	organizationID := d.Get("organization_id").(string)
+
+	ids, err := req.Storage.List(ctx, issuedAPIKeyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	keyInfo := map[string]interface{}{}
+	for _, id := range ids {
+		issued, err := getIssuedAPIKey(ctx, req.Storage, id)
+		if err != nil {
+			return nil, err
+		}
+		if issued == nil {
+			continue
+		}
+
+		if role != "" && issued.Role != role {
+			continue
+		}
+		if projectID != "" && issued.ProjectID != projectID {
+			continue
+		}
+		if organizationID != "" && issued.OrganizationID != organizationID {
+			continue
+		}
+
+		keys = append(keys, id)
+		keyInfo[id] = issued.toResponseData()
+	}
+
+	return logical.ListResponseWithInfo(keys, keyInfo), nil
+}
+
+func (b *Backend) pathKeysRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	issued, err := getIssuedAPIKey(ctx, req.Storage, d.Get("programmatic_api_key_id").(string))
+	if err != nil {
+		return nil, err
+	}
+	if issued == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: issued.toResponseData(),
+	}, nil
+}
+
+const pathKeysListHelpSyn = `List the MongoDB Atlas Programmatic API Keys issued by this backend`
+const pathKeysListHelpDesc = `
+Keys are listed by their ID, along with the role that issued them, their
+organization and project, and the time they were issued. The list can be
+filtered by "role", "project_id" and "organization_id".
+`
+
+const pathKeysHelpSyn = `Read a MongoDB Atlas Programmatic API Key issued by this backend`
+const pathKeysHelpDesc = `
+This path reads the role, organization, project and issue time of a
+Programmatic API Key issued by this backend that hasn't been revoked yet.
+
+The lease ID isn't known when the key is issued, so "request_id" holds the ID
+of the request that issued the key, which the audit log maps to the lease.
+`
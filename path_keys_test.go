@@ -0,0 +1,112 @@
+package mongodbatlas
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func TestBackend_PathKeys(t *testing.T) {
+	b, storage, atlas := newFakeAtlasBackend(t)
+	defer atlas.Close()
+
+	for name, data := range map[string]map[string]interface{}{
+		"org-key": {
+			"organization_id": fakeOrganizationID,
+			"roles":           []string{"ORG_MEMBER"},
+		},
+		"project-key": {
+			"project_id": fakeProjectID,
+			"roles":      []string{"GROUP_READ_ONLY"},
+		},
+	} {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.CreateOperation,
+			Path:      "roles/" + name,
+			Storage:   storage,
+			Data:      data,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("bad: role creation failed:. resp:%#v err:%v", resp, err)
+		}
+	}
+
+	secrets := map[string]*logical.Secret{}
+	for _, name := range []string{"org-key", "project-key"} {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			ID:        "request-" + name,
+			Operation: logical.ReadOperation,
+			Path:      "creds/" + name,
+			Storage:   storage,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("bad: reading credentials failed:. resp:%#v err:%v", resp, err)
+		}
+		secrets[name] = resp.Secret
+	}
+	projectKeyID := secrets["project-key"].InternalData["programmatic_api_key_id"].(string)
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ListOperation,
+		Path:      "keys/",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"project_id": fakeProjectID,
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: listing keys failed:. resp:%#v err:%v", resp, err)
+	}
+	keys := resp.Data["keys"].([]string)
+	if len(keys) != 1 || keys[0] != projectKeyID {
+		t.Fatalf("bad: unexpected keys %v", keys)
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ListOperation,
+		Path:      "keys/",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"role": "org-key",
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: listing keys failed:. resp:%#v err:%v", resp, err)
+	}
+	keys = resp.Data["keys"].([]string)
+	if len(keys) != 1 || keys[0] != secrets["org-key"].InternalData["programmatic_api_key_id"] {
+		t.Fatalf("bad: unexpected keys %v", keys)
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "keys/" + projectKeyID,
+		Storage:   storage,
+	})
+	if err != nil || resp == nil || resp.IsError() {
+		t.Fatalf("bad: reading key failed:. resp:%#v err:%v", resp, err)
+	}
+	if resp.Data["role"] != "project-key" || resp.Data["organization_id"] != fakeOrganizationID ||
+		resp.Data["public_key"] != atlas.key(projectKeyID).PublicKey || resp.Data["request_id"] != "request-project-key" {
+		t.Fatalf("bad: unexpected key %v", resp.Data)
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.RevokeOperation,
+		Storage:   storage,
+		Secret:    secrets["project-key"],
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: revoking credentials failed:. resp:%#v err:%v", resp, err)
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "keys/" + projectKeyID,
+		Storage:   storage,
+	})
+	if err != nil || resp != nil {
+		t.Fatalf("expected the revoked key to be removed. resp:%#v err:%v", resp, err)
+	}
+}
@@ -0,0 +1,192 @@
+package mongodbatlas
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/strutil"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/mongodb/go-client-mongodb-atlas/mongodbatlas"
+)
+
+func (b *Backend) pathLookupPublicKey() *framework.Path {
+	return &framework.Path{
+		Pattern: "lookup/public-key/" + framework.GenericNameRegex("public_key"),
+		Fields: map[string]*framework.FieldSchema{
+			"public_key": {
+				Type:        framework.TypeString,
+				Description: "Public key of the Programmatic API Key",
+				Required:    true,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathLookupPublicKeyRead,
+		},
+
+		HelpSynopsis:    pathLookupPublicKeyHelpSyn,
+		HelpDescription: pathLookupPublicKeyHelpDesc,
+	}
+}
+
+func (b *Backend) pathRevokePublicKey() *framework.Path {
+	return &framework.Path{
+		Pattern: "revoke/public-key/" + framework.GenericNameRegex("public_key"),
+		Fields: map[string]*framework.FieldSchema{
+			"public_key": {
+				Type:        framework.TypeString,
+				Description: "Public key of the Programmatic API Key",
+				Required:    true,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathRevokePublicKeyUpdate,
+		},
+
+		HelpSynopsis:    pathRevokePublicKeyHelpSyn,
+		HelpDescription: pathRevokePublicKeyHelpDesc,
+	}
+}
+
+func (b *Backend) pathLookupPublicKeyRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	issued, err := getIssuedAPIKeyByPublicKey(ctx, req.Storage, d.Get("public_key").(string))
+	if err != nil {
+		return nil, err
+	}
+	if issued == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: issued.toResponseData(),
+	}, nil
+}
+
+func (b *Backend) pathRevokePublicKeyUpdate(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	publicKey := d.Get("public_key").(string)
+
+	issued, err := getIssuedAPIKeyByPublicKey(ctx, req.Storage, publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if issued != nil {
+		if err := b.deleteProgrammaticAPIKey(ctx, req.Storage, &walEntry{
+			OrganizationID:       issued.OrganizationID,
+			ProjectID:            issued.ProjectID,
+			Connection:           issued.Connection,
+			ProgrammaticAPIKeyID: issued.APIKeyID,
+		}); err != nil {
+			return nil, errwrap.Wrapf("error deleting programmatic API key: {{err}}", err)
+		}
+
+		if err := deleteIssuedAPIKey(ctx, req.Storage, issued.APIKeyID); err != nil {
+			return nil, errwrap.Wrapf("error deleting issued programmatic API key: {{err}}", err)
+		}
+
+		return &logical.Response{
+			Data: issued.toResponseData(),
+		}, nil
+	}
+
+	// Keys that are no longer tracked, such as those whose lease is gone,
+	// are looked up in MongoDB Atlas
+	key, orgID, connection, err := b.findOrphanedAPIKeyByPublicKey(ctx, req.Storage, publicKey)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return logical.ErrorResponse("no programmatic API key issued by this backend has public key %q", publicKey), nil
+	}
+
+	client, err := b.connectionClient(ctx, req.Storage, connection)
+	if err != nil {
+		return nil, err
+	}
+	if err := deleteAPIKey(ctx, client, orgID, key.ID); err != nil {
+		return nil, errwrap.Wrapf("error deleting programmatic API key: {{err}}", err)
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"programmatic_api_key_id": key.ID,
+			"public_key":              key.PublicKey,
+			"organization_id":         orgID,
+			"description":             key.Desc,
+		},
+	}, nil
+}
+
+// findOrphanedAPIKeyByPublicKey searches the organizations the backend may
+// have created keys in for an untracked key created by the backend with the
+// given public key. It returns the key, its organization and the connection
+// it was found with, or a nil key if there is none.
+func (b *Backend) findOrphanedAPIKeyByPublicKey(ctx context.Context, s logical.Storage, publicKey string) (*mongodbatlas.APIKey, string, string, error) {
+	connections, err := connectionNames(ctx, s)
+	if err != nil {
+		return nil, "", "", err
+	}
+	for _, connection := range connections {
+		cfg, err := getConnectionConfig(ctx, s, connection)
+		if err != nil {
+			return nil, "", "", err
+		}
+		if publicKey == cfg.PublicKey {
+			return nil, "", "", nil
+		}
+	}
+
+	staticIDs, err := staticRoleAPIKeyIDs(ctx, s)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	for _, connection := range connections {
+		client, err := b.connectionClient(ctx, s, connection)
+		if err != nil {
+			return nil, "", "", err
+		}
+
+		orgIDs, err := tidyOrganizations(ctx, s, client, connection)
+		if err != nil {
+			return nil, "", "", err
+		}
+
+		for _, orgID := range orgIDs {
+			keys, err := listAPIKeys(ctx, client, orgID)
+			if err != nil {
+				return nil, "", "", errwrap.Wrapf(fmt.Sprintf("error listing programmatic API keys of organization %q: {{err}}", orgID), err)
+			}
+			for i := range keys {
+				if keys[i].PublicKey != publicKey || !issuedDescriptionRegex.MatchString(keys[i].Desc) || strutil.StrListContains(staticIDs, keys[i].ID) {
+					continue
+				}
+				return &keys[i], orgID, connection, nil
+			}
+		}
+	}
+
+	return nil, "", "", nil
+}
+
+const pathLookupPublicKeyHelpSyn = `
+Look up a MongoDB Atlas Programmatic API Key issued by this backend by its public key.
+`
+const pathLookupPublicKeyHelpDesc = `
+This path returns the role, lease, requesting entity and issue time of the
+Programmatic API Key with the given public key, as recorded when the key was
+issued.
+`
+
+const pathRevokePublicKeyHelpSyn = `
+Delete a MongoDB Atlas Programmatic API Key issued by this backend by its public key.
+`
+const pathRevokePublicKeyHelpDesc = `
+This path deletes the Programmatic API Key with the given public key from
+MongoDB Atlas right away. Keys whose lease is gone are looked up in the
+organizations of the configured credentials and of the roles. The Vault
+lease of the key, if any, is left to expire or be revoked as usual.
+`
@@ -0,0 +1,107 @@
+package mongodbatlas
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func TestBackend_PathPublicKeys(t *testing.T) {
+	b, storage, atlas := newFakeAtlasBackend(t)
+	defer atlas.Close()
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      "roles/test-programmatic-key",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"organization_id": fakeOrganizationID,
+			"roles":           []string{"ORG_MEMBER"},
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: role creation failed:. resp:%#v err:%v", resp, err)
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "creds/test-programmatic-key",
+		Storage:   storage,
+		EntityID:  "test-entity",
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: reading credentials failed:. resp:%#v err:%v", resp, err)
+	}
+	publicKey := resp.Data["public_key"].(string)
+	keyID := resp.Secret.InternalData["programmatic_api_key_id"].(string)
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "lookup/public-key/" + publicKey,
+		Storage:   storage,
+	})
+	if err != nil || resp == nil || resp.IsError() {
+		t.Fatalf("bad: lookup failed:. resp:%#v err:%v", resp, err)
+	}
+	if resp.Data["role"] != "test-programmatic-key" || resp.Data["entity_id"] != "test-entity" || resp.Data["programmatic_api_key_id"] != keyID {
+		t.Fatalf("bad: unexpected lookup %v", resp.Data)
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "revoke/public-key/" + publicKey,
+		Storage:   storage,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: revoking by public key failed:. resp:%#v err:%v", resp, err)
+	}
+	if atlas.key(keyID) != nil {
+		t.Fatal("expected the programmatic API key to be deleted")
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "lookup/public-key/" + publicKey,
+		Storage:   storage,
+	})
+	if err != nil || resp != nil {
+		t.Fatalf("expected the revoked key to be removed. resp:%#v err:%v", resp, err)
+	}
+}
+
+func TestBackend_PathPublicKeys_Untracked(t *testing.T) {
+	b, storage, atlas := newFakeAtlasBackend(t)
+	defer atlas.Close()
+
+	atlas.Lock()
+	orphan := atlas.addKey(fakeOrganizationID, "vault-test-programmatic-key-aaaaaaaaaaaaaaaaaaaa", nil)
+	manual := atlas.addKey(fakeOrganizationID, "admin", nil)
+	atlas.Unlock()
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "revoke/public-key/" + orphan.PublicKey,
+		Storage:   storage,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: revoking by public key failed:. resp:%#v err:%v", resp, err)
+	}
+	if atlas.key(orphan.ID) != nil {
+		t.Fatal("expected the untracked key to be deleted")
+	}
+
+	for _, publicKey := range []string{manual.PublicKey, atlas.key(atlas.rootKey).PublicKey} {
+		resp, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "revoke/public-key/" + publicKey,
+			Storage:   storage,
+		})
+		if err != nil || resp == nil || !resp.IsError() {
+			t.Fatalf("expected error response. resp:%#v err:%v", resp, err)
+		}
+	}
+	if atlas.key(manual.ID) == nil || atlas.key(atlas.rootKey) == nil {
+		t.Fatal("expected the keys not created by the backend to remain")
+	}
+}
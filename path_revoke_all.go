@@ -0,0 +1,401 @@
+package mongodbatlas
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sync"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/strutil"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/mongodb/go-client-mongodb-atlas/mongodbatlas"
+)
+
+// revokeAllParallelism bounds the number of keys deleted concurrently by the
+// revoke-all endpoints.
+const revokeAllParallelism = 8
+
+func (b *Backend) pathRoleRevokeAll() *framework.Path {
+	return &framework.Path{
+		Pattern: "roles/" + roleNameRegex("name") + "/revoke-all$",
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeLowerCaseString,
+				Description: "Name of the role",
+				Required:    true,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathRoleRevokeAllUpdate,
+		},
+
+		HelpSynopsis:    pathRoleRevokeAllHelpSyn,
+		HelpDescription: pathRoleRevokeAllHelpDesc,
+	}
+}
+
+func (b *Backend) pathOrganizationRevokeAll() *framework.Path {
+	return &framework.Path{
+		Pattern: "revoke-all/organization/" + framework.GenericNameRegex("organization_id"),
+		Fields: map[string]*framework.FieldSchema{
+			"organization_id": {
+				Type:        framework.TypeString,
+				Description: "Organization ID",
+				Required:    true,
+			},
+			"connection": {
+				Type:        framework.TypeLowerCaseString,
+				Description: "Name of the connection to find the untracked keys with. Defaults to the credentials of the config endpoint.",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathOrganizationRevokeAllUpdate,
+		},
+
+		HelpSynopsis:    pathOrganizationRevokeAllHelpSyn,
+		HelpDescription: pathOrganizationRevokeAllHelpDesc,
+	}
+}
+
+func (b *Backend) pathProjectRevokeAll() *framework.Path {
+	return &framework.Path{
+		Pattern: "revoke-all/project/" + framework.GenericNameRegex("project_id"),
+		Fields: map[string]*framework.FieldSchema{
+			"project_id": {
+				Type:        framework.TypeString,
+				Description: "Project ID",
+				Required:    true,
+			},
+			"connection": {
+				Type:        framework.TypeLowerCaseString,
+				Description: "Name of the connection to find the untracked keys with. Defaults to the credentials of the config endpoint.",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathProjectRevokeAllUpdate,
+		},
+
+		HelpSynopsis:    pathProjectRevokeAllHelpSyn,
+		HelpDescription: pathProjectRevokeAllHelpDesc,
+	}
+}
+
+func (b *Backend) pathRoleRevokeAllUpdate(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	// Keys of roles that have since been deleted are found through the
+	// organizations of the keys they issued
+	scopes := map[revokeScope]bool{}
+	cred, err := b.credentialRead(ctx, req.Storage, name)
+	if err != nil {
+		return nil, errwrap.Wrapf("error retrieving role: {{err}}", err)
+	}
+	if cred != nil && cred.CredentialType != databaseUser {
+		orgID := cred.OrganizationID
+		if orgID == "" {
+			client, err := b.connectionClient(ctx, req.Storage, cred.Connection)
+			if err != nil {
+				return nil, err
+			}
+			if orgID, err = projectOrganizationID(ctx, client, cred.ProjectID); err != nil {
+				return nil, err
+			}
+		}
+		scopes[revokeScope{Connection: cred.Connection, OrganizationID: orgID}] = true
+	}
+
+	targets, err := b.collectIssuedAPIKeys(ctx, req.Storage, func(issued *issuedAPIKeyEntry) bool {
+		if issued.Role != name {
+			return false
+		}
+		if issued.OrganizationID != "" {
+			scopes[revokeScope{Connection: issued.Connection, OrganizationID: issued.OrganizationID}] = true
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Keys issued before the descriptions identified the mount are revoked
+	// as well
+	mountID, err := b.getMountID(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	descriptionRegex := regexp.MustCompile("^vault-" + regexp.QuoteMeta(displayNameRegex.ReplaceAllString(name, "_")) + "-(" + regexp.QuoteMeta(mountID) + "-)?[a-zA-Z0-9]{20}$")
+	for scope := range scopes {
+		client, err := b.connectionClient(ctx, req.Storage, scope.Connection)
+		if err != nil {
+			return nil, err
+		}
+		keys, err := listAPIKeys(ctx, client, scope.OrganizationID)
+		if err != nil {
+			return nil, errwrap.Wrapf(fmt.Sprintf("error listing programmatic API keys of organization %q: {{err}}", scope.OrganizationID), err)
+		}
+		if targets, err = addUntrackedAPIKeys(ctx, req.Storage, targets, scope, keys, descriptionRegex); err != nil {
+			return nil, err
+		}
+	}
+
+	return revokeAllResponse(b.revokeAPIKeys(ctx, req.Storage, targets)), nil
+}
+
+func (b *Backend) pathOrganizationRevokeAllUpdate(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	orgID := d.Get("organization_id").(string)
+	connection := d.Get("connection").(string)
+
+	client, err := b.connectionClient(ctx, req.Storage, connection)
+	if err != nil {
+		return nil, err
+	}
+
+	targets, err := b.collectIssuedAPIKeys(ctx, req.Storage, func(issued *issuedAPIKeyEntry) bool {
+		return issued.OrganizationID == orgID
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := listAPIKeys(ctx, client, orgID)
+	if err != nil {
+		return nil, errwrap.Wrapf(fmt.Sprintf("error listing programmatic API keys of organization %q: {{err}}", orgID), err)
+	}
+	if targets, err = addUntrackedAPIKeys(ctx, req.Storage, targets, revokeScope{Connection: connection, OrganizationID: orgID}, keys, issuedDescriptionRegex); err != nil {
+		return nil, err
+	}
+
+	return revokeAllResponse(b.revokeAPIKeys(ctx, req.Storage, targets)), nil
+}
+
+func (b *Backend) pathProjectRevokeAllUpdate(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	projectID := d.Get("project_id").(string)
+	connection := d.Get("connection").(string)
+
+	client, err := b.connectionClient(ctx, req.Storage, connection)
+	if err != nil {
+		return nil, err
+	}
+
+	// The keys with access to a project are keys of its organization
+	orgID, err := projectOrganizationID(ctx, client, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	targets, err := b.collectIssuedAPIKeys(ctx, req.Storage, func(issued *issuedAPIKeyEntry) bool {
+		return issued.ProjectID == projectID
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := listProjectAPIKeys(ctx, client, projectID)
+	if err != nil {
+		return nil, errwrap.Wrapf(fmt.Sprintf("error listing programmatic API keys of project %q: {{err}}", projectID), err)
+	}
+	if targets, err = addUntrackedAPIKeys(ctx, req.Storage, targets, revokeScope{Connection: connection, OrganizationID: orgID}, keys, issuedDescriptionRegex); err != nil {
+		return nil, err
+	}
+
+	return revokeAllResponse(b.revokeAPIKeys(ctx, req.Storage, targets)), nil
+}
+
+// revokeScope is an organization searched for untracked keys, along with the
+// connection it is searched with.
+type revokeScope struct {
+	Connection     string
+	OrganizationID string
+}
+
+// revokeTarget is a programmatic API key to delete, and the outcome of its
+// deletion.
+type revokeTarget struct {
+	APIKeyID       string
+	PublicKey      string
+	OrganizationID string
+	Connection     string
+	Description    string
+	Role           string
+	Err            error
+}
+
+// collectIssuedAPIKeys returns the tracked keys matching a filter.
+func (b *Backend) collectIssuedAPIKeys(ctx context.Context, s logical.Storage, filter func(*issuedAPIKeyEntry) bool) ([]*revokeTarget, error) {
+	ids, err := s.List(ctx, issuedAPIKeyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var targets []*revokeTarget
+	for _, id := range ids {
+		issued, err := getIssuedAPIKey(ctx, s, id)
+		if err != nil {
+			return nil, err
+		}
+		if issued == nil || !filter(issued) {
+			continue
+		}
+
+		orgID := issued.OrganizationID
+		if orgID == "" {
+			client, err := b.connectionClient(ctx, s, issued.Connection)
+			if err != nil {
+				return nil, err
+			}
+			if orgID, err = projectOrganizationID(ctx, client, issued.ProjectID); err != nil {
+				return nil, err
+			}
+		}
+
+		targets = append(targets, &revokeTarget{
+			APIKeyID:       issued.APIKeyID,
+			PublicKey:      issued.PublicKey,
+			OrganizationID: orgID,
+			Connection:     issued.Connection,
+			Description:    issued.Description,
+			Role:           issued.Role,
+		})
+	}
+	return targets, nil
+}
+
+// addUntrackedAPIKeys adds the keys of an organization whose description
+// matches descriptionRegex and that aren't targeted yet. The credentials of
+// all connections, which may share the organization, and the keys of static
+// roles are never added.
+func addUntrackedAPIKeys(ctx context.Context, s logical.Storage, targets []*revokeTarget, scope revokeScope, keys []mongodbatlas.APIKey, descriptionRegex *regexp.Regexp) ([]*revokeTarget, error) {
+	connectionKeys, err := connectionPublicKeys(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+
+	protectedIDs, err := staticRoleAPIKeyIDs(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	for _, target := range targets {
+		protectedIDs = append(protectedIDs, target.APIKeyID)
+	}
+
+	for _, key := range keys {
+		if !descriptionRegex.MatchString(key.Desc) || connectionKeys[key.PublicKey] || strutil.StrListContains(protectedIDs, key.ID) {
+			continue
+		}
+		protectedIDs = append(protectedIDs, key.ID)
+		targets = append(targets, &revokeTarget{
+			APIKeyID:       key.ID,
+			PublicKey:      key.PublicKey,
+			OrganizationID: scope.OrganizationID,
+			Connection:     scope.Connection,
+			Description:    key.Desc,
+		})
+	}
+	return targets, nil
+}
+
+// revokeAPIKeys deletes the targeted keys concurrently, recording the outcome
+// of each deletion in its target.
+func (b *Backend) revokeAPIKeys(ctx context.Context, s logical.Storage, targets []*revokeTarget) []*revokeTarget {
+	semaphore := make(chan struct{}, revokeAllParallelism)
+
+	var wg sync.WaitGroup
+	for _, target := range targets {
+		wg.Add(1)
+		go func(target *revokeTarget) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			client, err := b.connectionClient(ctx, s, target.Connection)
+			if err != nil {
+				target.Err = err
+				return
+			}
+			if err := deleteAPIKey(ctx, client, target.OrganizationID, target.APIKeyID); err != nil {
+				target.Err = err
+				return
+			}
+			if err := deleteIssuedAPIKey(ctx, s, target.APIKeyID); err != nil {
+				target.Err = errwrap.Wrapf("the key was deleted, but not its issued key entry: {{err}}", err)
+			}
+		}(target)
+	}
+	wg.Wait()
+
+	return targets
+}
+
+func revokeAllResponse(targets []*revokeTarget) *logical.Response {
+	keys := make([]map[string]interface{}, 0, len(targets))
+	failed := 0
+	for _, target := range targets {
+		key := map[string]interface{}{
+			"programmatic_api_key_id": target.APIKeyID,
+			"public_key":              target.PublicKey,
+			"organization_id":         target.OrganizationID,
+			"description":             target.Description,
+			"role":                    target.Role,
+			"revoked":                 target.Err == nil,
+		}
+		if target.Err != nil {
+			key["error"] = target.Err.Error()
+			failed++
+		}
+		keys = append(keys, key)
+	}
+
+	resp := &logical.Response{
+		Data: map[string]interface{}{
+			"keys":    keys,
+			"revoked": len(targets) - failed,
+			"failed":  failed,
+		},
+	}
+	if failed > 0 {
+		resp.AddWarning(fmt.Sprintf("%d programmatic API keys could not be revoked", failed))
+	}
+	return resp
+}
+
+const pathRoleRevokeAllHelpSyn = `
+Delete all the MongoDB Atlas Programmatic API Keys issued by a role.
+`
+const pathRoleRevokeAllHelpDesc = `
+This path deletes every Programmatic API Key issued by the role, including
+the keys Vault no longer tracks, which are found by their description in the
+organization of the role. The leases of the keys are left in place, and
+revoking them later succeeds.
+
+A report of the deletion of each key is returned.
+`
+
+const pathOrganizationRevokeAllHelpSyn = `
+Delete all the MongoDB Atlas Programmatic API Keys issued by this backend in an organization.
+`
+const pathOrganizationRevokeAllHelpDesc = `
+This path deletes every Programmatic API Key of the organization that was
+issued by this backend, including the keys Vault no longer tracks, which are
+found by their description. The root credentials, the credentials of the
+named connections and the keys of static roles are never deleted.
+
+A report of the deletion of each key is returned.
+`
+
+const pathProjectRevokeAllHelpSyn = `
+Delete all the MongoDB Atlas Programmatic API Keys issued by this backend with access to a project.
+`
+const pathProjectRevokeAllHelpDesc = `
+This path deletes every Programmatic API Key with access to the project that
+was issued by this backend, including the keys Vault no longer tracks, which
+are found by their description. The keys are deleted from the organization
+of the project. The root credentials, the credentials of the named
+connections and the keys of static roles are never deleted.
+
+A report of the deletion of each key is returned.
+`
@@ -0,0 +1,155 @@
+package mongodbatlas
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/mongodb/go-client-mongodb-atlas/mongodbatlas"
+)
+
+func TestBackend_RevokeAll(t *testing.T) {
+	b, storage, atlas := newFakeAtlasBackend(t)
+	defer atlas.Close()
+
+	for name, data := range map[string]map[string]interface{}{
+		"org-key": {
+			"organization_id": fakeOrganizationID,
+			"roles":           []string{"ORG_MEMBER"},
+		},
+		"project-key": {
+			"project_id": fakeProjectID,
+			"roles":      []string{"GROUP_READ_ONLY"},
+		},
+	} {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.CreateOperation,
+			Path:      "roles/" + name,
+			Storage:   storage,
+			Data:      data,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("bad: role creation failed:. resp:%#v err:%v", resp, err)
+		}
+	}
+
+	issue := func(role string) string {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "creds/" + role,
+			Storage:   storage,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("bad: reading credentials failed:. resp:%#v err:%v", resp, err)
+		}
+		return resp.Secret.InternalData["programmatic_api_key_id"].(string)
+	}
+
+	orgKeys := []string{issue("org-key"), issue("org-key")}
+	projectKey := issue("project-key")
+
+	atlas.Lock()
+	untracked := atlas.addKey(fakeOrganizationID, "vault-org-key-aaaaaaaaaaaaaaaaaaaa", nil)
+	otherRole := atlas.addKey(fakeOrganizationID, "vault-org-key-other-aaaaaaaaaaaaaaaaaaaa", nil)
+	untrackedProject := atlas.addKey(fakeOrganizationID, "vault-other-aaaaaaaaaaaaaaaaaaaa", []mongodbatlas.APIKeyRole{
+		{GroupID: fakeProjectID, RoleName: "GROUP_OWNER"},
+	})
+	manualProject := atlas.addKey(fakeOrganizationID, "admin", []mongodbatlas.APIKeyRole{
+		{GroupID: fakeProjectID, RoleName: "GROUP_OWNER"},
+	})
+	atlas.Unlock()
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/org-key/revoke-all",
+		Storage:   storage,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: revoke-all failed:. resp:%#v err:%v", resp, err)
+	}
+	if resp.Data["revoked"] != 3 || resp.Data["failed"] != 0 {
+		t.Fatalf("bad: unexpected report %v", resp.Data)
+	}
+	for _, id := range append(orgKeys, untracked.ID) {
+		if atlas.key(id) != nil {
+			t.Fatalf("expected key %q of the role to be deleted", id)
+		}
+	}
+	if atlas.key(otherRole.ID) == nil || atlas.key(projectKey) == nil {
+		t.Fatal("expected the keys of other roles to remain")
+	}
+
+	// Failures are reported per key
+	atlas.fail(http.MethodDelete, "key", http.StatusInternalServerError)
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "revoke-all/project/" + fakeProjectID,
+		Storage:   storage,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: revoke-all failed:. resp:%#v err:%v", resp, err)
+	}
+	if resp.Data["revoked"] != 0 || resp.Data["failed"] != 2 || len(resp.Warnings) != 1 {
+		t.Fatalf("bad: unexpected report %v", resp.Data)
+	}
+	atlas.recover(http.MethodDelete, "key")
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "revoke-all/project/" + fakeProjectID,
+		Storage:   storage,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: revoke-all failed:. resp:%#v err:%v", resp, err)
+	}
+	if resp.Data["revoked"] != 2 {
+		t.Fatalf("bad: unexpected report %v", resp.Data)
+	}
+	if atlas.key(projectKey) != nil || atlas.key(untrackedProject.ID) != nil {
+		t.Fatal("expected the keys of the project to be deleted")
+	}
+	if atlas.key(manualProject.ID) == nil {
+		t.Fatal("expected the keys not created by the backend to remain")
+	}
+
+	// The key of another connection to the organization, left with the
+	// description of an interrupted root rotation
+	atlas.Lock()
+	connectionKey := atlas.addKey(fakeOrganizationID, rootRotationDescriptionPrefix+"aaaaaaaaaaaaaaaaaaaa", []mongodbatlas.APIKeyRole{
+		{OrgID: fakeOrganizationID, RoleName: "ORG_OWNER"},
+	})
+	atlas.Unlock()
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "connections/second",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"public_key":        connectionKey.PublicKey,
+			"private_key":       connectionKey.PrivateKey,
+			"base_url":          atlas.URL(),
+			"verify_connection": false,
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: connection write failed:. resp:%#v err:%v", resp, err)
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "revoke-all/organization/" + fakeOrganizationID,
+		Storage:   storage,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: revoke-all failed:. resp:%#v err:%v", resp, err)
+	}
+	if resp.Data["revoked"] != 1 || atlas.key(otherRole.ID) != nil {
+		t.Fatalf("bad: unexpected report %v", resp.Data)
+	}
+	if atlas.key(atlas.rootKey) == nil || atlas.key(manualProject.ID) == nil {
+		t.Fatal("expected the keys not created by the backend to remain")
+	}
+	if atlas.key(connectionKey.ID) == nil {
+		t.Fatal("expected the key of the other connection to remain")
+	}
+}
@@ -3,29 +3,50 @@ package mongodbatlas
 import (
 	"context"
 	"fmt"
+	"net"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/strutil"
 	"github.com/hashicorp/vault/sdk/logical"
 )
 
+// reservedRoleNameSegments can't end a nested role name, as the paths under
+// the credentials and roles endpoints of a role end with them.
+var reservedRoleNameSegments = []string{"revoke-all", "access-list"}
+
+// roleNameRegex matches role names, which are made of slash-separated
+// segments so that policies can grant a prefix of roles.
+func roleNameRegex(name string) string {
+	return fmt.Sprintf(`(?P<%s>\w(([\w-.]+)?\w)?(/\w(([\w-.]+)?\w)?)*)`, name)
+}
+
 func (b *Backend) pathRoles() *framework.Path {
 	return &framework.Path{
-		Pattern: "roles/" + framework.GenericNameRegex("name"),
+		Pattern: "roles/" + roleNameRegex("name") + "$",
 		Fields: map[string]*framework.FieldSchema{
 			"name": {
 				Type:        framework.TypeLowerCaseString,
 				Description: "Name of the Roles",
 				Required:    true,
 			},
+			"connection": {
+				Type:        framework.TypeLowerCaseString,
+				Description: "Name of the connection to issue the credentials with, configured with connections/<connection_name>. Defaults to the credentials of the config endpoint.",
+			},
+			"credential_type": {
+				Type:        framework.TypeString,
+				Description: fmt.Sprintf("Type of credential issued by the role, either %q or %q. Defaults to %q.", programmaticAPIKey, databaseUser, programmaticAPIKey),
+			},
 			"project_id": {
 				Type:        framework.TypeString,
-				Description: fmt.Sprintf("Project ID the %s API key belongs to.", projectProgrammaticAPIKey),
+				Description: fmt.Sprintf("Project ID the %s API key belongs to, required for %s credentials.", projectProgrammaticAPIKey, databaseUser),
 			},
 			"roles": {
 				Type:        framework.TypeCommaStringSlice,
-				Description: fmt.Sprintf("List of roles that the API Key should be granted. A minimum of one role must be provided. Any roles provided must be valid for the assigned Project, required for %s and %s keys.", orgProgrammaticAPIKey, projectProgrammaticAPIKey),
-				Required:    true,
+				Description: fmt.Sprintf("List of roles that the API Key should be granted. A minimum of one role must be provided. Any roles provided must be valid for the assigned Project, required when creating %s and %s roles.", orgProgrammaticAPIKey, projectProgrammaticAPIKey),
 			},
 			"organization_id": {
 				Type:        framework.TypeString,
@@ -39,10 +60,30 @@ func (b *Backend) pathRoles() *framework.Path {
 				Type:        framework.TypeCommaStringSlice,
 				Description: fmt.Sprintf("Whitelist entry in CIDR notation to be added for the API key. Optional for %s and %s keys.", orgProgrammaticAPIKey, projectProgrammaticAPIKey),
 			},
+			"bind_client_ip": {
+				Type:        framework.TypeBool,
+				Description: fmt.Sprintf("Whether to add the IP address of the client requesting the API key to its whitelist. Optional for %s and %s keys.", orgProgrammaticAPIKey, projectProgrammaticAPIKey),
+			},
+			"allowed_cidr_blocks": {
+				Type:        framework.TypeCommaStringSlice,
+				Description: "CIDR blocks within which a client IP address may be supplied when requesting an API key with bind_client_ip, and within which the whitelist of issued API keys may be changed.",
+			},
 			"project_roles": {
 				Type:        framework.TypeCommaStringSlice,
 				Description: fmt.Sprintf("Roles assigned when an %s API Key is assigned to a %s API key", orgProgrammaticAPIKey, projectProgrammaticAPIKey),
 			},
+			"database_name": {
+				Type:        framework.TypeString,
+				Description: fmt.Sprintf("Database of the %s roles that don't specify one. Defaults to \"admin\".", databaseUser),
+			},
+			"database_roles": {
+				Type:        framework.TypeCommaStringSlice,
+				Description: fmt.Sprintf("Roles granted to the %s, in the form \"role@database\" or \"role@database.collection\". Required for %s credentials.", databaseUser, databaseUser),
+			},
+			"scopes": {
+				Type:        framework.TypeCommaStringSlice,
+				Description: fmt.Sprintf("Names of the clusters the %s is restricted to. Defaults to all the clusters of the project.", databaseUser),
+			},
 			"ttl": {
 				Type:        framework.TypeDurationSecond,
 				Description: `Duration in seconds after which the issued credential should expire. Defaults to 0, in which case the value will fallback to the system/mount defaults.`,
@@ -51,12 +92,20 @@ func (b *Backend) pathRoles() *framework.Path {
 				Type:        framework.TypeDurationSecond,
 				Description: "The maximum allowed lifetime of credentials issued using this role.",
 			},
+			"validate": {
+				Type:        framework.TypeBool,
+				Description: "If true, the roles are checked against the roles MongoDB Atlas grants at the level of the key, and the organization and project against the MongoDB Atlas API before the role is stored. Defaults to false.",
+			},
 		},
 
+		ExistenceCheck: b.pathRolesExistenceCheck,
+
 		Callbacks: map[logical.Operation]framework.OperationFunc{
 			logical.DeleteOperation: b.pathRolesDelete,
 			logical.ReadOperation:   b.pathRolesRead,
+			logical.CreateOperation: b.pathRolesWrite,
 			logical.UpdateOperation: b.pathRolesWrite,
+			logical.PatchOperation:  b.pathRolesWrite,
 		},
 
 		HelpSynopsis:    pathRolesHelpSyn,
@@ -64,6 +113,14 @@ func (b *Backend) pathRoles() *framework.Path {
 	}
 }
 
+func (b *Backend) pathRolesExistenceCheck(ctx context.Context, req *logical.Request, d *framework.FieldData) (bool, error) {
+	entry, err := b.credentialRead(ctx, req.Storage, d.Get("name").(string))
+	if err != nil {
+		return false, err
+	}
+	return entry != nil, nil
+}
+
 func (b *Backend) pathRolesDelete(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
 	err := req.Storage.Delete(ctx, "roles/"+d.Get("name").(string))
 	return nil, err
@@ -89,6 +146,10 @@ func (b *Backend) pathRolesWrite(ctx context.Context, req *logical.Request, d *f
 	if credentialName == "" {
 		return logical.ErrorResponse("missing role name"), nil
 	}
+	segments := strings.Split(credentialName, "/")
+	if len(segments) > 1 && strutil.StrListContains(reservedRoleNameSegments, segments[len(segments)-1]) {
+		return logical.ErrorResponse("role names can't end with %q", segments[len(segments)-1]), nil
+	}
 
 	b.credentialMutex.Lock()
 	defer b.credentialMutex.Unlock()
@@ -97,37 +158,66 @@ func (b *Backend) pathRolesWrite(ctx context.Context, req *logical.Request, d *f
 		return nil, err
 	}
 
-	if credentialEntry == nil {
+	// Updates and patches only change the supplied fields of the stored
+	// role. Vault turns writes of a role that doesn't exist into creates
+	// with the existence check, so only creates start from an empty role.
+	switch {
+	case credentialEntry == nil && req.Operation != logical.CreateOperation:
+		return logical.ErrorResponse("role %q does not exist", credentialName), nil
+	case credentialEntry == nil:
 		credentialEntry = &atlasCredentialEntry{}
 	}
 
+	if credentialTypeRaw, ok := d.GetOk("credential_type"); ok {
+		credentialEntry.CredentialType = credentialTypeRaw.(string)
+	}
+
+	// The key level types are accepted as they were documented before
+	// credential types were introduced
+	switch credentialEntry.CredentialType {
+	case "", programmaticAPIKey, "org_programmatic_api_key", "project_programmatic_api_key":
+		credentialEntry.CredentialType = programmaticAPIKey
+	case databaseUser:
+	default:
+		return logical.ErrorResponse("credential_type must be %q or %q", programmaticAPIKey, databaseUser), nil
+	}
+
+	if connectionRaw, ok := d.GetOk("connection"); ok {
+		credentialEntry.Connection = connectionRaw.(string)
+	}
+	if credentialEntry.Connection != "" {
+		cfg, err := readConnectionConfig(ctx, req.Storage, credentialEntry.Connection)
+		if err != nil {
+			return nil, err
+		}
+		if cfg == nil {
+			return logical.ErrorResponse("connection %q does not exist", credentialEntry.Connection), nil
+		}
+	}
+
 	if organizationIDRaw, ok := d.GetOk("organization_id"); ok {
 		credentialEntry.OrganizationID = organizationIDRaw.(string)
 	}
 
 	getAPIWhitelistArgs(credentialEntry, d)
+	if errResp := getClientIPBindingArgs(credentialEntry, d); errResp != nil {
+		return errResp, nil
+	}
 
 	if projectIDRaw, ok := d.GetOk("project_id"); ok {
 		projectID := projectIDRaw.(string)
 		credentialEntry.ProjectID = projectID
 	}
 
-	if len(credentialEntry.OrganizationID) == 0 && len(credentialEntry.ProjectID) == 0 {
-		return logical.ErrorResponse("organization_id or project_id are required"), nil
-	}
-
-	if programmaticKeyRolesRaw, ok := d.GetOk("roles"); ok {
-		credentialEntry.Roles = programmaticKeyRolesRaw.([]string)
-	} else {
-		return logical.ErrorResponse("%s is required for %s and %s keys", "roles", orgProgrammaticAPIKey, projectProgrammaticAPIKey), nil
+	var errResp *logical.Response
+	switch credentialEntry.CredentialType {
+	case programmaticAPIKey:
+		errResp = programmaticAPIKeyRoleArgs(credentialEntry, d)
+	case databaseUser:
+		errResp = databaseUserRoleArgs(credentialEntry, d)
 	}
-
-	if projectRolesRaw, ok := d.GetOk("project_roles"); ok {
-		credentialEntry.ProjectRoles = projectRolesRaw.([]string)
-	} else {
-		if isAssignedToProject(credentialEntry.OrganizationID, credentialEntry.ProjectID) {
-			return logical.ErrorResponse("%s is required if both %s and %s are supplied", "roles", "organization_id", "project_id"), nil
-		}
+	if errResp != nil {
+		return errResp, nil
 	}
 
 	if ttlRaw, ok := d.GetOk("ttl"); ok {
@@ -142,6 +232,16 @@ func (b *Backend) pathRolesWrite(ctx context.Context, req *logical.Request, d *f
 		return logical.ErrorResponse("ttl exceeds max_ttl"), nil
 	}
 
+	if d.Get("validate").(bool) {
+		if errResp, err := b.validateRole(ctx, req, credentialEntry); errResp != nil || err != nil {
+			return errResp, err
+		}
+	}
+
+	if errResp, err := b.checkRoleAllowed(ctx, req.Storage, credentialEntry); errResp != nil || err != nil {
+		return errResp, err
+	}
+
 	if err := setAtlasCredential(ctx, req.Storage, credentialName, credentialEntry); err != nil {
 		return nil, err
 	}
@@ -149,6 +249,148 @@ func (b *Backend) pathRolesWrite(ctx context.Context, req *logical.Request, d *f
 	return &resp, nil
 }
 
+// checkRoleAllowed checks the roles, organization and project of a role
+// against the allow-lists of its connection. Roles of the root configuration
+// are written before it may be, so there is nothing to check them against
+// until it is.
+func (b *Backend) checkRoleAllowed(ctx context.Context, s logical.Storage, credentialEntry *atlasCredentialEntry) (*logical.Response, error) {
+	var cfg *config
+	var err error
+	if credentialEntry.Connection == "" {
+		cfg, err = readRootConfig(ctx, s)
+	} else {
+		cfg, err = readConnectionConfig(ctx, s, credentialEntry.Connection)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if cfg == nil {
+		if credentialEntry.Connection != "" {
+			return logical.ErrorResponse("connection %q does not exist", credentialEntry.Connection), nil
+		}
+		return nil, nil
+	}
+
+	var orgRoles []string
+	projectRoles := map[string][]string{}
+	switch {
+	case credentialEntry.CredentialType != programmaticAPIKey:
+		projectRoles[credentialEntry.ProjectID] = nil
+	case isOrgKey(credentialEntry.OrganizationID, credentialEntry.ProjectID):
+		orgRoles = credentialEntry.Roles
+	case isProjectKey(credentialEntry.OrganizationID, credentialEntry.ProjectID):
+		projectRoles[credentialEntry.ProjectID] = credentialEntry.Roles
+	case isAssignedToProject(credentialEntry.OrganizationID, credentialEntry.ProjectID):
+		orgRoles = credentialEntry.Roles
+		projectRoles[credentialEntry.ProjectID] = credentialEntry.ProjectRoles
+	}
+
+	// Project keys and database users are created in the organization of
+	// their project
+	organizationID := credentialEntry.OrganizationID
+	if organizationID == "" && len(cfg.AllowedOrganizationIDs) > 0 {
+		client, err := b.connectionClient(ctx, s, credentialEntry.Connection)
+		if err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+		organizationID, err = projectOrganizationID(ctx, client, credentialEntry.ProjectID)
+		if err != nil {
+			return logical.ErrorResponse("error checking the organization of the role against allowed_organization_ids: %s", err), nil
+		}
+	}
+
+	if err := checkAllowLists(cfg, organizationID, orgRoles, projectRoles); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+	return nil, nil
+}
+
+// checkAllowLists checks an organization, its roles and the roles in each of
+// its projects against the allow-lists of the configuration. An empty
+// organization is not checked.
+func checkAllowLists(cfg *config, organizationID string, orgRoles []string, projectRoles map[string][]string) error {
+	for _, role := range orgRoles {
+		if len(cfg.AllowedOrgRoles) > 0 && !strutil.StrListContains(cfg.AllowedOrgRoles, role) {
+			return fmt.Errorf("organization role %q is not allowed, allowed_org_roles is %v", role, cfg.AllowedOrgRoles)
+		}
+	}
+
+	projectIDs := make([]string, 0, len(projectRoles))
+	for projectID := range projectRoles {
+		projectIDs = append(projectIDs, projectID)
+	}
+	sort.Strings(projectIDs)
+	for _, projectID := range projectIDs {
+		for _, role := range projectRoles[projectID] {
+			if len(cfg.AllowedProjectRoles) > 0 && !strutil.StrListContains(cfg.AllowedProjectRoles, role) {
+				return fmt.Errorf("project role %q is not allowed, allowed_project_roles is %v", role, cfg.AllowedProjectRoles)
+			}
+		}
+	}
+	for _, projectID := range projectIDs {
+		if projectID != "" && len(cfg.AllowedProjectIDs) > 0 && !strutil.StrListContains(cfg.AllowedProjectIDs, projectID) {
+			return fmt.Errorf("project %q is not allowed, allowed_project_ids is %v", projectID, cfg.AllowedProjectIDs)
+		}
+	}
+
+	if organizationID != "" && len(cfg.AllowedOrganizationIDs) > 0 && !strutil.StrListContains(cfg.AllowedOrganizationIDs, organizationID) {
+		return fmt.Errorf("organization %q is not allowed, allowed_organization_ids is %v", organizationID, cfg.AllowedOrganizationIDs)
+	}
+	return nil
+}
+
+func programmaticAPIKeyRoleArgs(credentialEntry *atlasCredentialEntry, d *framework.FieldData) *logical.Response {
+	if len(credentialEntry.OrganizationID) == 0 && len(credentialEntry.ProjectID) == 0 {
+		return logical.ErrorResponse("organization_id or project_id are required")
+	}
+
+	if programmaticKeyRolesRaw, ok := d.GetOk("roles"); ok {
+		credentialEntry.Roles = programmaticKeyRolesRaw.([]string)
+	}
+	if len(credentialEntry.Roles) == 0 {
+		return logical.ErrorResponse("%s is required for %s and %s keys", "roles", orgProgrammaticAPIKey, projectProgrammaticAPIKey)
+	}
+
+	if projectRolesRaw, ok := d.GetOk("project_roles"); ok {
+		credentialEntry.ProjectRoles = projectRolesRaw.([]string)
+	}
+	if len(credentialEntry.ProjectRoles) == 0 && isAssignedToProject(credentialEntry.OrganizationID, credentialEntry.ProjectID) {
+		return logical.ErrorResponse("%s is required if both %s and %s are supplied", "project_roles", "organization_id", "project_id")
+	}
+
+	return nil
+}
+
+func databaseUserRoleArgs(credentialEntry *atlasCredentialEntry, d *framework.FieldData) *logical.Response {
+	if len(credentialEntry.ProjectID) == 0 {
+		return logical.ErrorResponse("project_id is required for %s credentials", databaseUser)
+	}
+
+	if len(credentialEntry.CIDRBlocks) > 0 || len(credentialEntry.IPAddresses) > 0 || credentialEntry.BindClientIP {
+		return logical.ErrorResponse("ip_addresses, cidr_blocks and bind_client_ip are not supported for %s credentials", databaseUser)
+	}
+
+	if databaseNameRaw, ok := d.GetOk("database_name"); ok {
+		credentialEntry.DatabaseName = databaseNameRaw.(string)
+	}
+
+	if databaseRolesRaw, ok := d.GetOk("database_roles"); ok {
+		credentialEntry.DatabaseRoles = databaseRolesRaw.([]string)
+	}
+	if len(credentialEntry.DatabaseRoles) == 0 {
+		return logical.ErrorResponse("database_roles is required for %s credentials", databaseUser)
+	}
+	if _, err := parseDatabaseRoles(credentialEntry.DatabaseRoles, credentialEntry.DatabaseName); err != nil {
+		return logical.ErrorResponse(err.Error())
+	}
+
+	if scopesRaw, ok := d.GetOk("scopes"); ok {
+		credentialEntry.Scopes = scopesRaw.([]string)
+	}
+
+	return nil
+}
+
 func getAPIWhitelistArgs(credentialEntry *atlasCredentialEntry, d *framework.FieldData) {
 
 	if cidrBlocks, ok := d.GetOk("cidr_blocks"); ok {
@@ -159,6 +401,22 @@ func getAPIWhitelistArgs(credentialEntry *atlasCredentialEntry, d *framework.Fie
 	}
 }
 
+func getClientIPBindingArgs(credentialEntry *atlasCredentialEntry, d *framework.FieldData) *logical.Response {
+	if bindClientIP, ok := d.GetOk("bind_client_ip"); ok {
+		credentialEntry.BindClientIP = bindClientIP.(bool)
+	}
+	if allowedCIDRBlocks, ok := d.GetOk("allowed_cidr_blocks"); ok {
+		credentialEntry.AllowedCIDRBlocks = allowedCIDRBlocks.([]string)
+	}
+
+	for _, cidrBlock := range credentialEntry.AllowedCIDRBlocks {
+		if _, _, err := net.ParseCIDR(cidrBlock); err != nil {
+			return logical.ErrorResponse("invalid allowed_cidr_blocks entry %q: %s", cidrBlock, err)
+		}
+	}
+	return nil
+}
+
 func setAtlasCredential(ctx context.Context, s logical.Storage, credentialName string, credentialEntry *atlasCredentialEntry) error {
 	if credentialName == "" {
 		return fmt.Errorf("empty role name")
@@ -194,6 +452,11 @@ func (b *Backend) credentialRead(ctx context.Context, s logical.Storage, credent
 		if err := entry.DecodeJSON(&credentialEntry); err != nil {
 			return nil, err
 		}
+		// Roles written before credential types were introduced issue
+		// programmatic API keys
+		if credentialEntry.CredentialType == "" {
+			credentialEntry.CredentialType = programmaticAPIKey
+		}
 		return &credentialEntry, nil
 	}
 	// Return nil here because all callers expect that if an entry
@@ -202,28 +465,39 @@ func (b *Backend) credentialRead(ctx context.Context, s logical.Storage, credent
 }
 
 type atlasCredentialEntry struct {
-	ProjectID      string        `json:"project_id"`
-	DatabaseName   string        `json:"database_name"`
-	Roles          []string      `json:"roles"`
-	OrganizationID string        `json:"organization_id"`
-	CIDRBlocks     []string      `json:"cidr_blocks"`
-	IPAddresses    []string      `json:"ip_addresses"`
-	ProjectRoles   []string      `json:"project_roles"`
-	TTL            time.Duration `json:"ttl"`
-	MaxTTL         time.Duration `json:"max_ttl"`
+	Connection        string        `json:"connection"`
+	CredentialType    string        `json:"credential_type"`
+	ProjectID         string        `json:"project_id"`
+	DatabaseName      string        `json:"database_name"`
+	DatabaseRoles     []string      `json:"database_roles"`
+	Scopes            []string      `json:"scopes"`
+	Roles             []string      `json:"roles"`
+	OrganizationID    string        `json:"organization_id"`
+	CIDRBlocks        []string      `json:"cidr_blocks"`
+	IPAddresses       []string      `json:"ip_addresses"`
+	ProjectRoles      []string      `json:"project_roles"`
+	BindClientIP      bool          `json:"bind_client_ip"`
+	AllowedCIDRBlocks []string      `json:"allowed_cidr_blocks"`
+	TTL               time.Duration `json:"ttl"`
+	MaxTTL            time.Duration `json:"max_ttl"`
 }
 
 func (r atlasCredentialEntry) toResponseData() map[string]interface{} {
 	respData := map[string]interface{}{
-		"project_id":      r.ProjectID,
-		"database_name":   r.DatabaseName,
-		"roles":           r.Roles,
-		"organization_id": r.OrganizationID,
-		"cidr_blocks":     r.CIDRBlocks,
-		"ip_addresses":    r.IPAddresses,
-		"project_roles":   r.ProjectRoles,
-		"ttl":             r.TTL.Seconds(),
-		"max_ttl":         r.MaxTTL.Seconds(),
+		"credential_type":     r.CredentialType,
+		"project_id":          r.ProjectID,
+		"database_name":       r.DatabaseName,
+		"database_roles":      r.DatabaseRoles,
+		"scopes":              r.Scopes,
+		"roles":               r.Roles,
+		"organization_id":     r.OrganizationID,
+		"cidr_blocks":         r.CIDRBlocks,
+		"ip_addresses":        r.IPAddresses,
+		"project_roles":       r.ProjectRoles,
+		"bind_client_ip":      r.BindClientIP,
+		"allowed_cidr_blocks": r.AllowedCIDRBlocks,
+		"ttl":                 r.TTL.Seconds(),
+		"max_ttl":             r.MaxTTL.Seconds(),
 	}
 	return respData
 }
@@ -248,13 +522,47 @@ to the Programmatic API keys created for a given role. At least one role should
 and must be valid for key level (project or org).
 
 "ip_addresses" and "cidr_blocks" are used to add whitelist entries for the API key.
+The whitelist of project keys is managed in the organization of the project.
+
+"bind_client_ip" adds the IP address of the client requesting an API key to
+the whitelist of the key, so that the key can't be used from another host. The
+client may instead supply a "client_ip" within one of the "allowed_cidr_blocks",
+such as when Vault only sees the address of a proxy.
+
+"allowed_cidr_blocks" also bounds the whitelist entries that can be added to
+or removed from an issued API key through "creds/<name>/access-list".
 
 "project_roles" is used when both "organization_id" and "project_id" are supplied. 
 And it's a list of roles that the API Key should be granted. A minimum of one role 
 must be provided. Any roles provided must be valid for the assigned Project
 
+"credential_type" set to "database_user" makes the role issue MongoDB Atlas
+database users in the project with the provided "project_id" instead of
+Programmatic API Keys. "database_roles" lists the roles granted to the user,
+in the form "role@database" or "role@database.collection"; roles without a
+database use "database_name", which defaults to "admin". "scopes" optionally
+restricts the user to the listed clusters.
+
+If "validate" is true, typos and roles of the wrong key level in "roles"
+and "project_roles", as well as unknown organizations and projects, or a
+project outside the organization, are reported when the role is written
+rather than when credentials are requested. The response has status 400 and
+lists the failures by field under "errors".
+
+"connection" issues the credentials with a named connection configured with
+"connections/<connection_name>", such as for another organization, instead
+of the credentials of the "config" endpoint.
+
+Patching or writing an existing role only changes the supplied parameters,
+so "roles" is only required when the role is created. Supplying an empty
+value clears a list parameter, such as "cidr_blocks" or "project_roles".
+Patching or updating a role that doesn't exist fails. Creating a role needs
+the "create" capability on its path, updating it the "update" capability and
+patching it the "patch" capability.
+
 To validate the keys, attempt to read an access key after writing the policy.
 `
 const orgProgrammaticAPIKey = `organization`
 const projectProgrammaticAPIKey = `project`
 const programmaticAPIKey = `programmatic_api_key`
+const databaseUser = `database_user`
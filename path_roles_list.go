@@ -2,6 +2,7 @@ package mongodbatlas
 
 import (
 	"context"
+	"strings"
 
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
@@ -9,7 +10,13 @@ import (
 
 func (b *Backend) pathRolesList() *framework.Path {
 	return &framework.Path{
-		Pattern: "roles/?$",
+		Pattern: "roles(/|/" + roleNameRegex("prefix") + "/)?$",
+		Fields: map[string]*framework.FieldSchema{
+			"prefix": {
+				Type:        framework.TypeLowerCaseString,
+				Description: "Prefix of the roles to list, such as \"team-a\" for the roles named \"team-a/<name>\"",
+			},
+		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
 			logical.ListOperation: b.operationListRoles,
@@ -21,7 +28,12 @@ func (b *Backend) pathRolesList() *framework.Path {
 }
 
 func (b *Backend) operationListRoles(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
-	entries, err := req.Storage.List(ctx, "roles/")
+	path := "roles/"
+	if prefix := d.Get("prefix").(string); prefix != "" {
+		path += prefix + "/"
+	}
+
+	entries, err := req.Storage.List(ctx, path)
 	if err != nil {
 		return nil, err
 	}
@@ -29,5 +41,31 @@ func (b *Backend) operationListRoles(ctx context.Context, req *logical.Request,
 	return logical.ListResponse(entries), nil
 }
 
+// listRoleNames returns the full names of all the roles, including those
+// nested under a prefix.
+func listRoleNames(ctx context.Context, s logical.Storage) ([]string, error) {
+	var names []string
+	prefixes := []string{""}
+	for len(prefixes) > 0 {
+		prefix := prefixes[0]
+		prefixes = prefixes[1:]
+
+		entries, err := s.List(ctx, "roles/"+prefix)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if strings.HasSuffix(entry, "/") {
+				prefixes = append(prefixes, prefix+entry)
+				continue
+			}
+			names = append(names, prefix+entry)
+		}
+	}
+	return names, nil
+}
+
 const pathRolesListHelpSyn = `List the existing roles in this backend`
-const pathRolesListHelpDesc = `Roles will be listed by the role name.`
+const pathRolesListHelpDesc = `Roles will be listed by the role name. Roles named with slashes, such as
+"team-a/app-1", are listed under their prefix: listing "roles/" returns
+"team-a/", and listing "roles/team-a/" returns "app-1".`
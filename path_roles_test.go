@@ -2,7 +2,11 @@ package mongodbatlas
 
 import (
 	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
 	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/hashicorp/vault/sdk/logical"
@@ -27,7 +31,7 @@ func TestBackend_PathListCredentials(t *testing.T) {
 	}
 
 	credReq := &logical.Request{
-		Operation: logical.UpdateOperation,
+		Operation: logical.CreateOperation,
 		Storage:   config.StorageView,
 		Data:      credData,
 	}
@@ -67,3 +71,474 @@ func TestBackend_PathListCredentials(t *testing.T) {
 		t.Fatalf("failed to list all 10 credentials")
 	}
 }
+
+func TestBackend_PathRoles_Allowed(t *testing.T) {
+	b, storage, atlas := newFakeAtlasBackend(t)
+	defer atlas.Close()
+
+	const otherProjectID = "5cf5a45a9ccf6400e60981b7"
+	atlas.Lock()
+	atlas.projects[otherProjectID] = "5b71ff2f96e82120d0aaec15"
+	atlas.Unlock()
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"allowed_org_roles":        []string{"ORG_MEMBER", "ORG_READ_ONLY"},
+			"allowed_project_roles":    []string{"GROUP_READ_ONLY"},
+			"allowed_organization_ids": []string{fakeOrganizationID},
+			"allowed_project_ids":      []string{fakeProjectID, otherProjectID},
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: config write failed:. resp:%#v err:%v", resp, err)
+	}
+
+	for name, tc := range map[string]struct {
+		data    map[string]interface{}
+		allowed bool
+	}{
+		"org key": {
+			data: map[string]interface{}{
+				"organization_id": fakeOrganizationID,
+				"roles":           []string{"ORG_MEMBER"},
+			},
+			allowed: true,
+		},
+		"org owner": {
+			data: map[string]interface{}{
+				"organization_id": fakeOrganizationID,
+				"roles":           []string{"ORG_MEMBER", "ORG_OWNER"},
+			},
+		},
+		"other organization": {
+			data: map[string]interface{}{
+				"organization_id": "5b71ff2f96e82120d0aaec15",
+				"roles":           []string{"ORG_MEMBER"},
+			},
+		},
+		"project key": {
+			data: map[string]interface{}{
+				"project_id": fakeProjectID,
+				"roles":      []string{"GROUP_READ_ONLY"},
+			},
+			allowed: true,
+		},
+		"project owner": {
+			data: map[string]interface{}{
+				"project_id": fakeProjectID,
+				"roles":      []string{"GROUP_OWNER"},
+			},
+		},
+		"project in other organization": {
+			data: map[string]interface{}{
+				"project_id": otherProjectID,
+				"roles":      []string{"GROUP_READ_ONLY"},
+			},
+		},
+		"other project": {
+			data: map[string]interface{}{
+				"project_id": "5cf5a45a9ccf6400e60981b8",
+				"roles":      []string{"GROUP_READ_ONLY"},
+			},
+		},
+		"assigned project owner": {
+			data: map[string]interface{}{
+				"organization_id": fakeOrganizationID,
+				"project_id":      fakeProjectID,
+				"roles":           []string{"ORG_MEMBER"},
+				"project_roles":   []string{"GROUP_OWNER"},
+			},
+		},
+		"database user": {
+			data: map[string]interface{}{
+				"credential_type": databaseUser,
+				"project_id":      fakeProjectID,
+				"database_roles":  []string{"read@admin"},
+			},
+			allowed: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			resp, err := b.HandleRequest(context.Background(), &logical.Request{
+				Operation: logical.CreateOperation,
+				Path:      "roles/" + strings.Replace(name, " ", "-", -1),
+				Storage:   storage,
+				Data:      tc.data,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.allowed && resp != nil && resp.IsError() {
+				t.Fatalf("expected the role to be allowed, got %#v", resp)
+			}
+			if !tc.allowed && (resp == nil || !resp.IsError()) {
+				t.Fatal("expected the role to be rejected")
+			}
+		})
+	}
+
+	// Roles written before the allow-lists changed can't issue credentials
+	// outside of them
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"allowed_org_roles": []string{"ORG_READ_ONLY"},
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: config write failed:. resp:%#v err:%v", resp, err)
+	}
+	keyCount := atlas.keyCount()
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "creds/org-key",
+		Storage:   storage,
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected credentials outside of the allow-lists to be refused, got resp:%#v err:%v", resp, err)
+	}
+	if atlas.keyCount() != keyCount {
+		t.Fatal("expected no key to be created")
+	}
+}
+
+func TestBackend_PathRoles_Validate(t *testing.T) {
+	b, storage, atlas := newFakeAtlasBackend(t)
+	defer atlas.Close()
+
+	const otherProjectID = "5cf5a45a9ccf6400e60981b7"
+	atlas.Lock()
+	atlas.projects[otherProjectID] = "5b71ff2f96e82120d0aaec15"
+	atlas.Unlock()
+
+	for name, tc := range map[string]struct {
+		data   map[string]interface{}
+		errors map[string]string
+	}{
+		"valid": {
+			data: map[string]interface{}{
+				"organization_id": fakeOrganizationID,
+				"project_id":      fakeProjectID,
+				"roles":           []string{"ORG_MEMBER"},
+				"project_roles":   []string{"GROUP_READ_ONLY"},
+			},
+		},
+		"typo": {
+			data: map[string]interface{}{
+				"organization_id": fakeOrganizationID,
+				"roles":           []string{"ORG_MEMBR"},
+			},
+			errors: map[string]string{"roles": `"ORG_MEMBR" is not a valid organization role`},
+		},
+		"wrong level": {
+			data: map[string]interface{}{
+				"project_id": fakeProjectID,
+				"roles":      []string{"ORG_MEMBER", "GROUP_READ_ONLY"},
+			},
+			errors: map[string]string{"roles": `"ORG_MEMBER" is not valid at the project level`},
+		},
+		"unknown organization and project": {
+			data: map[string]interface{}{
+				"organization_id": "5b71ff2f96e82120d0aaec16",
+				"project_id":      "5cf5a45a9ccf6400e60981b8",
+				"roles":           []string{"ORG_MEMBER"},
+				"project_roles":   []string{"GROUP_OWNR"},
+			},
+			errors: map[string]string{
+				"project_roles":   `"GROUP_OWNR" is not a valid project role`,
+				"organization_id": `organization "5b71ff2f96e82120d0aaec16" does not exist`,
+				"project_id":      `project "5cf5a45a9ccf6400e60981b8" does not exist`,
+			},
+		},
+		"project of other organization": {
+			data: map[string]interface{}{
+				"organization_id": fakeOrganizationID,
+				"project_id":      otherProjectID,
+				"roles":           []string{"ORG_MEMBER"},
+				"project_roles":   []string{"GROUP_READ_ONLY"},
+			},
+			errors: map[string]string{"project_id": `project "5cf5a45a9ccf6400e60981b7" belongs to organization "5b71ff2f96e82120d0aaec15"`},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			tc.data["validate"] = true
+			resp, err := b.HandleRequest(context.Background(), &logical.Request{
+				Operation: logical.CreateOperation,
+				Path:      "roles/" + strings.Replace(name, " ", "-", -1),
+				Storage:   storage,
+				Data:      tc.data,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(tc.errors) == 0 {
+				if resp != nil && resp.IsError() {
+					t.Fatalf("expected the role to be valid, got %#v", resp)
+				}
+				return
+			}
+			if resp == nil || resp.Data[logical.HTTPStatusCode] != http.StatusBadRequest {
+				t.Fatalf("expected the role to be invalid, got %#v", resp)
+			}
+			var body struct {
+				Data struct {
+					Error  string              `json:"error"`
+					Errors map[string][]string `json:"errors"`
+				} `json:"data"`
+			}
+			if err := json.Unmarshal([]byte(resp.Data[logical.HTTPRawBody].(string)), &body); err != nil {
+				t.Fatal(err)
+			}
+			if len(body.Data.Errors) != len(tc.errors) {
+				t.Fatalf("expected errors for %d fields, got %v", len(tc.errors), body.Data.Errors)
+			}
+			for field, expected := range tc.errors {
+				if len(body.Data.Errors[field]) != 1 || !strings.Contains(body.Data.Errors[field][0], expected) {
+					t.Fatalf("expected %s error %q, got %v", field, expected, body.Data.Errors[field])
+				}
+				if !strings.Contains(body.Data.Error, field+": "+expected) {
+					t.Fatalf("expected error %q, got %q", field+": "+expected, body.Data.Error)
+				}
+			}
+		})
+	}
+
+	// Roles are only validated on request
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      "roles/not-validated",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"organization_id": fakeOrganizationID,
+			"roles":           []string{"ORG_MEMBR"},
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: role creation failed:. resp:%#v err:%v", resp, err)
+	}
+}
+
+func TestBackend_PathRoles_Update(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+	config.System = logical.TestSystemView()
+
+	b := NewBackend(config.System)
+	if err := b.Setup(context.Background(), config); err != nil {
+		t.Fatal(err)
+	}
+
+	write := func(operation logical.Operation, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(context.Background(), &logical.Request{
+			Operation: operation,
+			Path:      "roles/test",
+			Storage:   config.StorageView,
+			Data:      data,
+		})
+	}
+	read := func() map[string]interface{} {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "roles/test",
+			Storage:   config.StorageView,
+		})
+		if err != nil || resp == nil || resp.IsError() {
+			t.Fatalf("bad: reading role failed:. resp:%#v err:%v", resp, err)
+		}
+		return resp.Data
+	}
+
+	existenceReq := &logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      "roles/test",
+		Storage:   config.StorageView,
+	}
+	_, exists, err := b.HandleExistenceCheck(context.Background(), existenceReq)
+	if err != nil || exists {
+		t.Fatalf("expected role test not to exist, got exists:%t err:%v", exists, err)
+	}
+
+	// Updates and patches don't create roles
+	for _, operation := range []logical.Operation{logical.UpdateOperation, logical.PatchOperation} {
+		resp, err := write(operation, map[string]interface{}{
+			"organization_id": fakeOrganizationID,
+			"roles":           []string{"ORG_MEMBER"},
+		})
+		if err != nil || resp == nil || !resp.IsError() {
+			t.Fatalf("expected %s of a missing role to fail, got resp:%#v err:%v", operation, resp, err)
+		}
+	}
+
+	resp, err := write(logical.CreateOperation, map[string]interface{}{
+		"organization_id": fakeOrganizationID,
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected creating a role without roles to fail, got resp:%#v err:%v", resp, err)
+	}
+
+	resp, err = write(logical.CreateOperation, map[string]interface{}{
+		"organization_id": fakeOrganizationID,
+		"project_id":      fakeProjectID,
+		"roles":           []string{"ORG_MEMBER"},
+		"project_roles":   []string{"GROUP_READ_ONLY"},
+		"cidr_blocks":     []string{"192.168.1.0/24"},
+		"ttl":             3600,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: role creation failed:. resp:%#v err:%v", resp, err)
+	}
+
+	_, exists, err = b.HandleExistenceCheck(context.Background(), existenceReq)
+	if err != nil || !exists {
+		t.Fatalf("expected role test to exist, got exists:%t err:%v", exists, err)
+	}
+
+	// Only the supplied fields change
+	resp, err = write(logical.PatchOperation, map[string]interface{}{
+		"max_ttl": 7200,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: role patch failed:. resp:%#v err:%v", resp, err)
+	}
+	data := read()
+	if data["max_ttl"] != float64(7200) || data["ttl"] != float64(3600) || len(data["roles"].([]string)) != 1 || len(data["cidr_blocks"].([]string)) != 1 {
+		t.Fatalf("expected only max_ttl to change, got %v", data)
+	}
+
+	// Empty values clear list fields, but not required ones
+	resp, err = write(logical.UpdateOperation, map[string]interface{}{
+		"roles": "",
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected clearing roles to fail, got resp:%#v err:%v", resp, err)
+	}
+	resp, err = write(logical.UpdateOperation, map[string]interface{}{
+		"project_roles": "",
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected clearing the project_roles of a key assigned to a project to fail, got resp:%#v err:%v", resp, err)
+	}
+
+	resp, err = write(logical.UpdateOperation, map[string]interface{}{
+		"project_id":    "",
+		"project_roles": "",
+		"cidr_blocks":   []string{},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: role update failed:. resp:%#v err:%v", resp, err)
+	}
+	data = read()
+	if data["project_id"] != "" || len(data["project_roles"].([]string)) != 0 || len(data["cidr_blocks"].([]string)) != 0 {
+		t.Fatalf("expected project_id, project_roles and cidr_blocks to be cleared, got %v", data)
+	}
+	if data["organization_id"] != fakeOrganizationID || data["max_ttl"] != float64(7200) {
+		t.Fatalf("expected the other fields to remain, got %v", data)
+	}
+}
+
+func TestBackend_PathRoles_Nested(t *testing.T) {
+	b, storage, atlas := newFakeAtlasBackend(t)
+	defer atlas.Close()
+
+	for _, name := range []string{"flat", "team-a/app-1", "team-a/app-2", "team-b/app-1"} {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.CreateOperation,
+			Path:      "roles/" + name,
+			Storage:   storage,
+			Data: map[string]interface{}{
+				"organization_id": fakeOrganizationID,
+				"roles":           []string{"ORG_MEMBER"},
+			},
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("bad: role %q creation failed:. resp:%#v err:%v", name, resp, err)
+		}
+	}
+
+	// Paths under a role aren't roles
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "roles/team-a/revoke-all",
+		Storage:   storage,
+	})
+	if err == nil && (resp == nil || !resp.IsError()) {
+		t.Fatalf("expected roles/team-a/revoke-all not to be a role, got resp:%#v", resp)
+	}
+	for _, name := range []string{"team-a/app-1/revoke-all", "team-a/access-list"} {
+		resp, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.CreateOperation,
+			Path:      "roles/" + name,
+			Storage:   storage,
+			Data: map[string]interface{}{
+				"organization_id": fakeOrganizationID,
+				"roles":           []string{"ORG_MEMBER"},
+			},
+		})
+		if err == nil && (resp == nil || !resp.IsError()) {
+			t.Fatalf("expected role %q to be rejected, got resp:%#v", name, resp)
+		}
+	}
+
+	for prefix, expected := range map[string][]string{
+		"":        {"flat", "team-a/", "team-b/"},
+		"team-a/": {"app-1", "app-2"},
+	} {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ListOperation,
+			Path:      "roles/" + prefix,
+			Storage:   storage,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("bad: listing roles/%s failed:. resp:%#v err:%v", prefix, resp, err)
+		}
+		if !reflect.DeepEqual(resp.Data["keys"], expected) {
+			t.Fatalf("expected roles/%s to list %v, got %v", prefix, expected, resp.Data["keys"])
+		}
+	}
+
+	issue := func(role string) (string, string) {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "creds/" + role,
+			Storage:   storage,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("bad: reading credentials of %q failed:. resp:%#v err:%v", role, resp, err)
+		}
+		return resp.Secret.InternalData["programmatic_api_key_id"].(string), resp.Data["public_key"].(string)
+	}
+	keyID, publicKey := issue("team-a/app-1")
+	otherKeyID, _ := issue("team-a/app-2")
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "creds/team-a/app-1/access-list",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"public_key": publicKey,
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: reading the access list failed:. resp:%#v err:%v", resp, err)
+	}
+	if resp.Data["programmatic_api_key_id"] != keyID {
+		t.Fatalf("expected the access list of key %q, got %v", keyID, resp.Data)
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/team-a/app-1/revoke-all",
+		Storage:   storage,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: revoke-all failed:. resp:%#v err:%v", resp, err)
+	}
+	if atlas.key(keyID) != nil || atlas.key(otherKeyID) == nil {
+		t.Fatal("expected only the key of role team-a/app-1 to be revoked")
+	}
+}
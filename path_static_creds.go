@@ -0,0 +1,354 @@
+package mongodbatlas
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/base62"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/mitchellh/mapstructure"
+	"github.com/mongodb/go-client-mongodb-atlas/mongodbatlas"
+)
+
+const staticRotationWALKind = "static_role_rotation"
+
+// staticRotationDescriptionPrefix starts the description of a static role key
+// being rotated in, which is unique to the rotation until it completes.
+const staticRotationDescriptionPrefix = "vault-static-rotation-"
+
+func (b *Backend) pathStaticCredentials() *framework.Path {
+	return &framework.Path{
+		Pattern: "static-creds/" + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeLowerCaseString,
+				Description: "Name of the static role",
+				Required:    true,
+			},
+		},
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathStaticCredentialsRead,
+		},
+
+		HelpSynopsis:    pathStaticCredentialsHelpSyn,
+		HelpDescription: pathStaticCredentialsHelpDesc,
+	}
+}
+
+func (b *Backend) pathRotateRole() *framework.Path {
+	return &framework.Path{
+		Pattern: "rotate-role/" + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeLowerCaseString,
+				Description: "Name of the static role",
+				Required:    true,
+			},
+		},
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathRotateRoleUpdate,
+		},
+
+		HelpSynopsis:    pathRotateRoleHelpSyn,
+		HelpDescription: pathRotateRoleHelpDesc,
+	}
+}
+
+func (b *Backend) pathStaticCredentialsRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	b.staticRoleMutex.Lock()
+	defer b.staticRoleMutex.Unlock()
+
+	entry, err := staticRoleRead(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return logical.ErrorResponse("unknown static role %q", name), nil
+	}
+
+	nextRotation := entry.LastRotated.Add(entry.RotationPeriod)
+	ttl := time.Until(nextRotation)
+	if ttl < 0 {
+		ttl = 0
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"public_key":              entry.PublicKey,
+			"private_key":             entry.PrivateKey,
+			"programmatic_api_key_id": entry.APIKeyID,
+			"last_rotated":            entry.LastRotated.Format(time.RFC3339),
+			"rotation_period":         entry.RotationPeriod.Seconds(),
+			"ttl":                     int64(ttl.Seconds()),
+		},
+	}, nil
+}
+
+func (b *Backend) pathRotateRoleUpdate(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	b.staticRoleMutex.Lock()
+	defer b.staticRoleMutex.Unlock()
+
+	entry, err := staticRoleRead(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return logical.ErrorResponse("unknown static role %q", name), nil
+	}
+
+	if err := b.rotateStaticRole(ctx, req.Storage, name, entry, true); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// rotateStaticRole replaces the key of a static role with a new one that has
+// the same roles and access list. Unless retire is false, the replaced key is
+// retired for the overlap period. The caller must hold the static role lock.
+//
+// As for the root key, the WAL entry is written before the new key is
+// created with a unique description recorded in it, which is restored to the
+// description of the replaced key once the rotation completes.
+func (b *Backend) rotateStaticRole(ctx context.Context, s logical.Storage, name string, entry *atlasStaticRoleEntry, retire bool) error {
+	client, err := b.clientMongo(ctx, s)
+	if err != nil {
+		return err
+	}
+
+	// The roles of the key may have been changed in MongoDB Atlas since the
+	// static role was written
+	key, _, err := client.APIKeys.Get(ctx, entry.OrganizationID, entry.APIKeyID)
+	if err != nil {
+		return errwrap.Wrapf("error reading programmatic API key: {{err}}", err)
+	}
+	if err := checkStaticRoleKey(ctx, s, entry.OrganizationID, key); err != nil {
+		return err
+	}
+
+	template, err := getAPIKeyTemplate(ctx, client, entry.OrganizationID, entry.APIKeyID)
+	if err != nil {
+		return err
+	}
+
+	marker, err := base62.Random(20)
+	if err != nil {
+		return errwrap.Wrapf("error generating rotation marker: {{err}}", err)
+	}
+	walEntry := &staticRotationWALEntry{
+		RoleName:       name,
+		OrganizationID: entry.OrganizationID,
+		Description:    staticRotationDescriptionPrefix + marker,
+	}
+	walID, err := framework.PutWAL(ctx, s, staticRotationWALKind, walEntry)
+	if err != nil {
+		return errwrap.Wrapf("error writing WAL entry: {{err}}", err)
+	}
+
+	// From here on a failure leaves the WAL entry in place so the rollback
+	// removes the new key.
+	newKey, err := createAPIKeyFromTemplate(ctx, client, &apiKeyTemplate{
+		OrganizationID: template.OrganizationID,
+		Description:    walEntry.Description,
+		Roles:          template.Roles,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Record the new key, so the rollback can tell whether it is in use
+	walEntry.NewAPIKeyID = newKey.ID
+	newWALID, err := framework.PutWAL(ctx, s, staticRotationWALKind, walEntry)
+	if err != nil {
+		return errwrap.Wrapf("error writing WAL entry: {{err}}", err)
+	}
+	if err := framework.DeleteWAL(ctx, s, walID); err != nil {
+		return errwrap.Wrapf("error deleting WAL entry: {{err}}", err)
+	}
+	walID = newWALID
+
+	if err := applyAPIKeyTemplate(ctx, client, template, newKey.ID); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	if retire {
+		entry.RetiredAPIKeys = append(entry.RetiredAPIKeys, retiredAPIKey{
+			ID:          entry.APIKeyID,
+			DeleteAfter: now.Add(entry.OverlapPeriod),
+		})
+	}
+	entry.APIKeyID = newKey.ID
+	entry.PublicKey = newKey.PublicKey
+	entry.PrivateKey = newKey.PrivateKey
+	entry.LastRotated = now
+
+	if err := setStaticRole(ctx, s, name, entry); err != nil {
+		return errwrap.Wrapf("error storing static role: {{err}}", err)
+	}
+
+	if _, _, err := client.APIKeys.Update(ctx, entry.OrganizationID, newKey.ID, &mongodbatlas.APIKeyInput{
+		Desc:  template.Description,
+		Roles: template.Roles,
+	}); err != nil {
+		b.Logger().Warn("error restoring the description of the programmatic API key", "static_role", name, "error", err)
+	}
+
+	if err := framework.DeleteWAL(ctx, s, walID); err != nil {
+		return errwrap.Wrapf("failed to commit WAL entry: {{err}}", err)
+	}
+
+	return b.deleteRetiredAPIKeys(ctx, s, name, entry)
+}
+
+// deleteRetiredAPIKeys deletes the replaced keys of a static role whose
+// overlap period has elapsed. The caller must hold the static role lock.
+func (b *Backend) deleteRetiredAPIKeys(ctx context.Context, s logical.Storage, name string, entry *atlasStaticRoleEntry) error {
+	now := time.Now()
+
+	var retired []retiredAPIKey
+	var merr error
+	for _, key := range entry.RetiredAPIKeys {
+		if now.Before(key.DeleteAfter) {
+			retired = append(retired, key)
+			continue
+		}
+
+		client, err := b.clientMongo(ctx, s)
+		if err == nil {
+			err = deleteAPIKey(ctx, client, entry.OrganizationID, key.ID)
+		}
+		if err != nil {
+			retired = append(retired, key)
+			merr = multierror.Append(merr, errwrap.Wrapf(fmt.Sprintf("error deleting replaced programmatic API key %q: {{err}}", key.ID), err))
+		}
+	}
+
+	if len(retired) == len(entry.RetiredAPIKeys) {
+		return merr
+	}
+
+	entry.RetiredAPIKeys = retired
+	if err := setStaticRole(ctx, s, name, entry); err != nil {
+		merr = multierror.Append(merr, errwrap.Wrapf("error storing static role: {{err}}", err))
+	}
+	return merr
+}
+
+// rotateStaticRolesIfDue rotates the static roles whose rotation period has
+// elapsed and deletes the replaced keys whose overlap period has elapsed.
+func (b *Backend) rotateStaticRolesIfDue(ctx context.Context, s logical.Storage) error {
+	names, err := s.List(ctx, staticRolePath)
+	if err != nil {
+		return err
+	}
+
+	b.staticRoleMutex.Lock()
+	defer b.staticRoleMutex.Unlock()
+
+	var merr error
+	now := time.Now()
+	for _, name := range names {
+		entry, err := staticRoleRead(ctx, s, name)
+		if err != nil {
+			merr = multierror.Append(merr, err)
+			continue
+		}
+		if entry == nil {
+			continue
+		}
+
+		backoff, ok := b.staticRoleBackoff[name]
+		if !ok {
+			backoff = &rotationBackoff{}
+			b.staticRoleBackoff[name] = backoff
+		}
+
+		if now.Before(entry.LastRotated.Add(entry.RotationPeriod)) || now.Before(backoff.next) {
+			if err := b.deleteRetiredAPIKeys(ctx, s, name, entry); err != nil {
+				merr = multierror.Append(merr, err)
+			}
+			continue
+		}
+
+		if err := b.rotateStaticRole(ctx, s, name, entry, true); err != nil {
+			delay := backoff.failed(now)
+			merr = multierror.Append(merr, errwrap.Wrapf(fmt.Sprintf("error rotating static role %q, retrying in %s: {{err}}", name, delay), err))
+			continue
+		}
+		backoff.reset()
+	}
+
+	return merr
+}
+
+func (b *Backend) staticRotationRollback(ctx context.Context, req *logical.Request, data interface{}) error {
+	var entry staticRotationWALEntry
+	if err := mapstructure.Decode(data, &entry); err != nil {
+		return err
+	}
+
+	b.staticRoleMutex.Lock()
+	defer b.staticRoleMutex.Unlock()
+
+	role, err := staticRoleRead(ctx, req.Storage, entry.RoleName)
+	if err != nil {
+		return err
+	}
+
+	client, err := b.clientMongo(ctx, req.Storage)
+	if err != nil {
+		return err
+	}
+
+	newKeyID := entry.NewAPIKeyID
+	if newKeyID == "" {
+		key, err := findAPIKeyByDescription(ctx, client, entry.OrganizationID, entry.Description)
+		if err != nil {
+			return err
+		}
+		if key == nil {
+			return nil
+		}
+		newKeyID = key.ID
+	}
+
+	// The rotation completed if the new key was stored
+	if role != nil && role.APIKeyID == newKeyID {
+		return nil
+	}
+
+	return deleteAPIKey(ctx, client, entry.OrganizationID, newKeyID)
+}
+
+type staticRotationWALEntry struct {
+	RoleName       string
+	OrganizationID string
+	NewAPIKeyID    string
+	Description    string
+}
+
+const pathStaticCredentialsHelpSyn = `
+Read the current MongoDB Atlas Programmatic API Key of a static role.
+`
+const pathStaticCredentialsHelpDesc = `
+This path reads the current MongoDB Atlas Programmatic API Key of a static
+role. "ttl" is the number of seconds until the key is next rotated.
+`
+
+const pathRotateRoleHelpSyn = `
+Request to rotate the MongoDB Atlas Programmatic API Key of a static role.
+`
+const pathRotateRoleHelpDesc = `
+This path replaces the MongoDB Atlas Programmatic API Key of a static role
+with a new one right away. The replaced key remains valid for the
+"overlap_period" of the static role.
+`
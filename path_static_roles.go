@@ -0,0 +1,335 @@
+package mongodbatlas
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/mongodb/go-client-mongodb-atlas/mongodbatlas"
+)
+
+const staticRolePath = "static-roles/"
+
+func (b *Backend) pathStaticRolesList() *framework.Path {
+	return &framework.Path{
+		Pattern: "static-roles/?$",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ListOperation: b.operationListStaticRoles,
+		},
+
+		HelpSynopsis:    pathStaticRolesListHelpSyn,
+		HelpDescription: pathStaticRolesListHelpDesc,
+	}
+}
+
+func (b *Backend) pathStaticRoles() *framework.Path {
+	return &framework.Path{
+		Pattern: "static-roles/" + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeLowerCaseString,
+				Description: "Name of the static role",
+				Required:    true,
+			},
+			"organization_id": {
+				Type:        framework.TypeString,
+				Description: "Organization ID the Programmatic API Key belongs to.",
+				Required:    true,
+			},
+			"programmatic_api_key_id": {
+				Type:        framework.TypeString,
+				Description: "ID of the existing Programmatic API Key managed by the static role.",
+				Required:    true,
+			},
+			"rotation_period": {
+				Type:        framework.TypeDurationSecond,
+				Description: "Period after which the Programmatic API Key is replaced by a new one.",
+				Required:    true,
+			},
+			"overlap_period": {
+				Type:        framework.TypeDurationSecond,
+				Description: "Period during which a replaced Programmatic API Key remains valid. Defaults to 0, in which case it is deleted immediately. The key bound when the static role is created is only deleted if this is set.",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.DeleteOperation: b.pathStaticRolesDelete,
+			logical.ReadOperation:   b.pathStaticRolesRead,
+			logical.UpdateOperation: b.pathStaticRolesWrite,
+		},
+
+		HelpSynopsis:    pathStaticRolesHelpSyn,
+		HelpDescription: pathStaticRolesHelpDesc,
+	}
+}
+
+func (b *Backend) operationListStaticRoles(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	entries, err := req.Storage.List(ctx, staticRolePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return logical.ListResponse(entries), nil
+}
+
+func (b *Backend) pathStaticRolesRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	entry, err := staticRoleRead(ctx, req.Storage, d.Get("name").(string))
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+	return &logical.Response{
+		Data: entry.toResponseData(),
+	}, nil
+}
+
+func (b *Backend) pathStaticRolesDelete(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	b.staticRoleMutex.Lock()
+	defer b.staticRoleMutex.Unlock()
+
+	entry, err := staticRoleRead(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	// The current key is left in place, but the replaced keys still within
+	// their overlap period are no longer needed.
+	if len(entry.RetiredAPIKeys) > 0 {
+		client, err := b.clientMongo(ctx, req.Storage)
+		if err != nil {
+			return nil, err
+		}
+		for _, retired := range entry.RetiredAPIKeys {
+			if err := deleteAPIKey(ctx, client, entry.OrganizationID, retired.ID); err != nil {
+				return nil, errwrap.Wrapf("error deleting replaced programmatic API key: {{err}}", err)
+			}
+		}
+	}
+
+	delete(b.staticRoleBackoff, name)
+
+	err = req.Storage.Delete(ctx, staticRolePath+name)
+	return nil, err
+}
+
+func (b *Backend) pathStaticRolesWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+	if name == "" {
+		return logical.ErrorResponse("missing role name"), nil
+	}
+
+	b.staticRoleMutex.Lock()
+	defer b.staticRoleMutex.Unlock()
+
+	entry, err := staticRoleRead(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+
+	create := entry == nil
+	if create {
+		entry = &atlasStaticRoleEntry{}
+	}
+
+	if organizationIDRaw, ok := d.GetOk("organization_id"); ok {
+		organizationID := organizationIDRaw.(string)
+		if !create && organizationID != entry.OrganizationID {
+			return logical.ErrorResponse("organization_id can't be changed"), nil
+		}
+		entry.OrganizationID = organizationID
+	}
+
+	if keyIDRaw, ok := d.GetOk("programmatic_api_key_id"); ok {
+		keyID := keyIDRaw.(string)
+		if !create && keyID != entry.APIKeyID {
+			return logical.ErrorResponse("programmatic_api_key_id can't be changed, it is updated by each rotation"), nil
+		}
+		entry.APIKeyID = keyID
+	}
+
+	if rotationPeriodRaw, ok := d.GetOk("rotation_period"); ok {
+		entry.RotationPeriod = time.Duration(rotationPeriodRaw.(int)) * time.Second
+	}
+
+	if overlapPeriodRaw, ok := d.GetOk("overlap_period"); ok {
+		entry.OverlapPeriod = time.Duration(overlapPeriodRaw.(int)) * time.Second
+	}
+
+	switch {
+	case entry.OrganizationID == "":
+		return logical.ErrorResponse("organization_id is required"), nil
+	case entry.APIKeyID == "":
+		return logical.ErrorResponse("programmatic_api_key_id is required"), nil
+	case entry.RotationPeriod <= 0:
+		return logical.ErrorResponse("rotation_period must be greater than 0"), nil
+	case entry.OverlapPeriod < 0:
+		return logical.ErrorResponse("overlap_period must not be negative"), nil
+	case entry.OverlapPeriod >= entry.RotationPeriod:
+		return logical.ErrorResponse("overlap_period must be less than rotation_period"), nil
+	}
+
+	// The private key of the existing key isn't known, so it is replaced
+	// right away. The existing key is left in place unless an overlap period
+	// is set, as it may still be in use.
+	if create {
+		client, err := b.clientMongo(ctx, req.Storage)
+		if err != nil {
+			return nil, err
+		}
+		key, res, err := client.APIKeys.Get(ctx, entry.OrganizationID, entry.APIKeyID)
+		if err != nil {
+			if res != nil && res.StatusCode == http.StatusNotFound {
+				return logical.ErrorResponse("programmatic API key %q not found in organization %q", entry.APIKeyID, entry.OrganizationID), nil
+			}
+			return nil, errwrap.Wrapf("error reading programmatic API key: {{err}}", err)
+		}
+		if err := checkStaticRoleKey(ctx, req.Storage, entry.OrganizationID, key); err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+
+		_, retire := d.GetOk("overlap_period")
+		if err := b.rotateStaticRole(ctx, req.Storage, name, entry, retire); err != nil {
+			return nil, err
+		}
+		return nil, nil
+	}
+
+	if err := setStaticRole(ctx, req.Storage, name, entry); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// checkStaticRoleKey checks that a key may be managed by a static role: it
+// must not be the key of the root configuration or of a connection, and its
+// roles must be within the allow-lists of the configuration.
+func checkStaticRoleKey(ctx context.Context, s logical.Storage, organizationID string, key *mongodbatlas.APIKey) error {
+	publicKeys, err := connectionPublicKeys(ctx, s)
+	if err != nil {
+		return err
+	}
+	if publicKeys[key.PublicKey] {
+		return fmt.Errorf("programmatic API key %q is used by the backend and can't be managed by a static role", key.ID)
+	}
+
+	cfg, err := readRootConfig(ctx, s)
+	if err != nil {
+		return err
+	}
+	if cfg == nil {
+		return nil
+	}
+
+	var orgRoles []string
+	projectRoles := map[string][]string{}
+	for _, role := range key.Roles {
+		switch {
+		case role.GroupID != "":
+			projectRoles[role.GroupID] = append(projectRoles[role.GroupID], role.RoleName)
+		default:
+			orgRoles = append(orgRoles, role.RoleName)
+		}
+	}
+	return checkAllowLists(cfg, organizationID, orgRoles, projectRoles)
+}
+
+func setStaticRole(ctx context.Context, s logical.Storage, name string, entry *atlasStaticRoleEntry) error {
+	if name == "" {
+		return fmt.Errorf("empty role name")
+	}
+	storageEntry, err := logical.StorageEntryJSON(staticRolePath+name, entry)
+	if err != nil {
+		return err
+	}
+	if storageEntry == nil {
+		return fmt.Errorf("nil result when writing to storage")
+	}
+	return s.Put(ctx, storageEntry)
+}
+
+func staticRoleRead(ctx context.Context, s logical.Storage, name string) (*atlasStaticRoleEntry, error) {
+	if name == "" {
+		return nil, fmt.Errorf("missing static role name")
+	}
+
+	entry, err := s.Get(ctx, staticRolePath+name)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var staticRole atlasStaticRoleEntry
+	if err := entry.DecodeJSON(&staticRole); err != nil {
+		return nil, err
+	}
+	return &staticRole, nil
+}
+
+type atlasStaticRoleEntry struct {
+	OrganizationID string          `json:"organization_id"`
+	APIKeyID       string          `json:"programmatic_api_key_id"`
+	PublicKey      string          `json:"public_key"`
+	PrivateKey     string          `json:"private_key"`
+	RotationPeriod time.Duration   `json:"rotation_period"`
+	OverlapPeriod  time.Duration   `json:"overlap_period"`
+	LastRotated    time.Time       `json:"last_rotated"`
+	RetiredAPIKeys []retiredAPIKey `json:"retired_api_keys"`
+}
+
+// retiredAPIKey is a replaced key that is deleted once its overlap period
+// has elapsed.
+type retiredAPIKey struct {
+	ID          string    `json:"id"`
+	DeleteAfter time.Time `json:"delete_after"`
+}
+
+func (r atlasStaticRoleEntry) toResponseData() map[string]interface{} {
+	return map[string]interface{}{
+		"organization_id":         r.OrganizationID,
+		"programmatic_api_key_id": r.APIKeyID,
+		"public_key":              r.PublicKey,
+		"rotation_period":         r.RotationPeriod.Seconds(),
+		"overlap_period":          r.OverlapPeriod.Seconds(),
+		"last_rotated":            r.LastRotated.Format(time.RFC3339),
+	}
+}
+
+const pathStaticRolesListHelpSyn = `List the existing static roles in this backend`
+const pathStaticRolesListHelpDesc = `Static roles will be listed by the role name.`
+
+const pathStaticRolesHelpSyn = `
+Manage the static roles that rotate existing MongoDB Atlas Programmatic API Keys.
+`
+const pathStaticRolesHelpDesc = `
+This path lets you manage the static roles, which are bound to an existing
+MongoDB Atlas Programmatic API Key identified by "organization_id" and
+"programmatic_api_key_id".
+
+As the secret of an existing key can't be changed, the key is rotated by
+creating a new key with the same roles, project assignments and whitelist
+entries. The replaced key remains valid for "overlap_period" before it is
+deleted. Keys are rotated when the static role is created and then every
+"rotation_period". The key bound when the static role is created is left in
+place unless "overlap_period" is set explicitly, as it may still be in use.
+
+The keys of the "config" endpoint and of named connections can't be bound to
+a static role, and the roles of the bound key must be within the allow-lists
+of the configuration.
+
+The current key is read from the "static-creds/" endpoint.
+`
@@ -0,0 +1,351 @@
+package mongodbatlas
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/mongodb/go-client-mongodb-atlas/mongodbatlas"
+)
+
+func addFakeStaticKey(atlas *fakeAtlas) *fakeAPIKey {
+	atlas.Lock()
+	defer atlas.Unlock()
+	key := atlas.addKey(fakeOrganizationID, "static key", []mongodbatlas.APIKeyRole{
+		{OrgID: fakeOrganizationID, RoleName: "ORG_MEMBER"},
+	})
+	key.accessList = []*mongodbatlas.WhitelistAPIKey{{CidrBlock: "192.168.1.0/24"}}
+	return key
+}
+
+func TestBackend_StaticRoles(t *testing.T) {
+	b, storage, atlas := newFakeAtlasBackend(t)
+	defer atlas.Close()
+
+	original := addFakeStaticKey(atlas)
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "static-roles/test-static",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"organization_id":         fakeOrganizationID,
+			"programmatic_api_key_id": original.ID,
+			"rotation_period":         3600,
+			"overlap_period":          600,
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: static role creation failed:. resp:%#v err:%v", resp, err)
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "static-creds/test-static",
+		Storage:   storage,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: reading static credentials failed:. resp:%#v err:%v", resp, err)
+	}
+
+	newKeyID := resp.Data["programmatic_api_key_id"].(string)
+	if newKeyID == original.ID {
+		t.Fatal("expected the key to be replaced when the static role is created")
+	}
+	newKey := atlas.key(newKeyID)
+	if newKey == nil || newKey.PrivateKey != resp.Data["private_key"] || newKey.PublicKey != resp.Data["public_key"] {
+		t.Fatal("expected the static credentials to match the new key")
+	}
+	if len(newKey.Roles) != 1 || newKey.Roles[0].RoleName != "ORG_MEMBER" {
+		t.Fatalf("bad: unexpected roles %v", newKey.Roles)
+	}
+	if len(newKey.accessList) != 1 || newKey.accessList[0].CidrBlock != "192.168.1.0/24" {
+		t.Fatalf("bad: unexpected access list %v", newKey.accessList)
+	}
+	if newKey.Desc != original.Desc {
+		t.Fatalf("expected the description of the replaced key to be restored, got %q", newKey.Desc)
+	}
+	if ttl := resp.Data["ttl"].(int64); ttl <= 0 || ttl > 3600 {
+		t.Fatalf("bad: unexpected ttl %d", ttl)
+	}
+
+	// The replaced key remains valid for the overlap period
+	if atlas.key(original.ID) == nil {
+		t.Fatal("expected the replaced key to remain during the overlap period")
+	}
+
+	// Expire the overlap period and the rotation period
+	b.staticRoleMutex.Lock()
+	entry, err := staticRoleRead(context.Background(), storage, "test-static")
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry.LastRotated = entry.LastRotated.Add(-2 * time.Hour)
+	entry.RetiredAPIKeys[0].DeleteAfter = time.Now().Add(-time.Minute)
+	if err := setStaticRole(context.Background(), storage, "test-static", entry); err != nil {
+		t.Fatal(err)
+	}
+	b.staticRoleMutex.Unlock()
+
+	if err := b.rotateStaticRolesIfDue(context.Background(), storage); err != nil {
+		t.Fatal(err)
+	}
+
+	if atlas.key(original.ID) != nil {
+		t.Fatal("expected the replaced key to be deleted after the overlap period")
+	}
+
+	entry, err = staticRoleRead(context.Background(), storage, "test-static")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.APIKeyID == newKeyID {
+		t.Fatal("expected the static role to be rotated after the rotation period")
+	}
+	if len(entry.RetiredAPIKeys) != 1 || entry.RetiredAPIKeys[0].ID != newKeyID {
+		t.Fatalf("bad: unexpected retired keys %v", entry.RetiredAPIKeys)
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.DeleteOperation,
+		Path:      "static-roles/test-static",
+		Storage:   storage,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: static role deletion failed:. resp:%#v err:%v", resp, err)
+	}
+
+	if atlas.key(newKeyID) != nil {
+		t.Fatal("expected the retired keys to be deleted with the static role")
+	}
+	if atlas.key(entry.APIKeyID) == nil {
+		t.Fatal("expected the current key to remain after the static role is deleted")
+	}
+}
+
+func TestBackend_StaticRoles_Rollback(t *testing.T) {
+	b, storage, atlas := newFakeAtlasBackend(t)
+	defer atlas.Close()
+
+	original := addFakeStaticKey(atlas)
+	keyCount := atlas.keyCount()
+
+	// Fail after the new key is created
+	atlas.fail(http.MethodPost, "access list", http.StatusInternalServerError)
+
+	_, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "static-roles/test-static",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"organization_id":         fakeOrganizationID,
+			"programmatic_api_key_id": original.ID,
+			"rotation_period":         3600,
+		},
+	})
+	if err == nil {
+		t.Fatal("expected static role creation to fail")
+	}
+	if atlas.keyCount() != keyCount+1 {
+		t.Fatalf("expected the new key to be left for the rollback, got %d keys", atlas.keyCount())
+	}
+
+	_, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.RollbackOperation,
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"immediate": true,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if atlas.keyCount() != keyCount || atlas.key(original.ID) == nil {
+		t.Fatal("expected only the original key to remain")
+	}
+
+	wals, err := framework.ListWAL(context.Background(), storage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(wals) != 0 {
+		t.Fatalf("expected no WAL entries, got %d", len(wals))
+	}
+}
+
+func TestBackend_StaticRoles_RollbackByDescription(t *testing.T) {
+	b, storage, atlas := newFakeAtlasBackend(t)
+	defer atlas.Close()
+
+	original := addFakeStaticKey(atlas)
+
+	// A rotation interrupted after creating the new key, before its ID was
+	// recorded
+	atlas.Lock()
+	newKey := atlas.addKey(fakeOrganizationID, staticRotationDescriptionPrefix+"aaaaaaaaaaaaaaaaaaaa", nil)
+	atlas.Unlock()
+	if _, err := framework.PutWAL(context.Background(), storage, staticRotationWALKind, &staticRotationWALEntry{
+		RoleName:       "test-static",
+		OrganizationID: fakeOrganizationID,
+		Description:    newKey.Desc,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.RollbackOperation,
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"immediate": true,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if atlas.key(newKey.ID) != nil || atlas.key(original.ID) == nil {
+		t.Fatal("expected the new key to be deleted and the original key to remain")
+	}
+}
+
+func TestBackend_StaticRoleValidation(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+
+	b := NewBackend(config.System)
+	if err := b.Setup(context.Background(), config); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, data := range map[string]map[string]interface{}{
+		"missing organization_id": {
+			"programmatic_api_key_id": "5d7f7e3d9ccf6400e60981b6",
+			"rotation_period":         3600,
+		},
+		"missing programmatic_api_key_id": {
+			"organization_id": fakeOrganizationID,
+			"rotation_period": 3600,
+		},
+		"missing rotation_period": {
+			"organization_id":         fakeOrganizationID,
+			"programmatic_api_key_id": "5d7f7e3d9ccf6400e60981b6",
+		},
+		"overlap_period too long": {
+			"organization_id":         fakeOrganizationID,
+			"programmatic_api_key_id": "5d7f7e3d9ccf6400e60981b6",
+			"rotation_period":         3600,
+			"overlap_period":          3600,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			resp, err := b.HandleRequest(context.Background(), &logical.Request{
+				Operation: logical.UpdateOperation,
+				Path:      "static-roles/test-static",
+				Storage:   config.StorageView,
+				Data:      data,
+			})
+			if err != nil || resp == nil || !resp.IsError() {
+				t.Fatalf("expected error response. resp:%#v err:%v", resp, err)
+			}
+		})
+	}
+}
+
+func TestBackend_StaticRoles_Create(t *testing.T) {
+	b, storage, atlas := newFakeAtlasBackend(t)
+	defer atlas.Close()
+
+	other := newOtherFakeAtlas()
+	defer other.Close()
+	otherRoot := other.key(other.rootKey)
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "connections/other",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"public_key":        otherRoot.PublicKey,
+			"private_key":       otherRoot.PrivateKey,
+			"verify_connection": false,
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: connection write failed:. resp:%#v err:%v", resp, err)
+	}
+
+	write := func(name string, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "static-roles/" + name,
+			Storage:   storage,
+			Data:      data,
+		})
+	}
+
+	// The keys of the backend can't be bound, even with another ID
+	atlas.Lock()
+	connectionKey := atlas.addKey(fakeOrganizationID, "connection key", []mongodbatlas.APIKeyRole{
+		{OrgID: fakeOrganizationID, RoleName: "ORG_OWNER"},
+	})
+	connectionKey.PublicKey = otherRoot.PublicKey
+	atlas.Unlock()
+	for name, keyID := range map[string]string{
+		"root":       atlas.rootKey,
+		"connection": connectionKey.ID,
+	} {
+		resp, err := write(name, map[string]interface{}{
+			"organization_id":         fakeOrganizationID,
+			"programmatic_api_key_id": keyID,
+			"rotation_period":         3600,
+		})
+		if err != nil || resp == nil || !resp.IsError() {
+			t.Fatalf("expected binding the %s key to be rejected, got resp:%#v err:%v", name, resp, err)
+		}
+	}
+	if atlas.key(atlas.rootKey) == nil || atlas.keyCount() != 2 {
+		t.Fatal("expected no key to be rotated or deleted")
+	}
+
+	// Without an overlap period the bound key is left in place
+	original := addFakeStaticKey(atlas)
+	resp, err = write("no-overlap", map[string]interface{}{
+		"organization_id":         fakeOrganizationID,
+		"programmatic_api_key_id": original.ID,
+		"rotation_period":         3600,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: static role creation failed:. resp:%#v err:%v", resp, err)
+	}
+	entry, err := staticRoleRead(context.Background(), storage, "no-overlap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.APIKeyID == original.ID || atlas.key(original.ID) == nil || len(entry.RetiredAPIKeys) != 0 {
+		t.Fatalf("expected the bound key to be replaced and left in place, got %+v", entry)
+	}
+
+	// The roles of the bound key must be allowed
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"allowed_org_roles": []string{"ORG_READ_ONLY"},
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: config write failed:. resp:%#v err:%v", resp, err)
+	}
+	resp, err = write("not-allowed", map[string]interface{}{
+		"organization_id":         fakeOrganizationID,
+		"programmatic_api_key_id": addFakeStaticKey(atlas).ID,
+		"rotation_period":         3600,
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected a key with roles outside the allow-lists to be rejected, got resp:%#v err:%v", resp, err)
+	}
+}
@@ -0,0 +1,447 @@
+package mongodbatlas
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/mitchellh/mapstructure"
+	"github.com/mongodb/go-client-mongodb-atlas/mongodbatlas"
+)
+
+// issuedDescriptionRegex matches the descriptions generated by
+// genAPIKeyDescription, and by genUsername before the descriptions identified
+// the mount.
+var issuedDescriptionRegex = regexp.MustCompile("^vault-[a-zA-Z0-9+=,.@_-]*-[a-zA-Z0-9]{20}$")
+
+// mountDescriptionRegex matches the descriptions generated by
+// genAPIKeyDescription for the mount.
+func mountDescriptionRegex(mountID string) *regexp.Regexp {
+	return regexp.MustCompile("^vault-[a-zA-Z0-9+=,.@_-]*-" + regexp.QuoteMeta(mountID) + "-[a-zA-Z0-9]{20}$")
+}
+
+func (b *Backend) pathTidyKeys() *framework.Path {
+	return &framework.Path{
+		Pattern: "tidy/keys",
+		Fields: map[string]*framework.FieldSchema{
+			"dry_run": {
+				Type:        framework.TypeBool,
+				Description: "If true, the orphaned Programmatic API Keys are only reported and not deleted.",
+			},
+		},
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathTidyKeysUpdate,
+		},
+
+		HelpSynopsis:    pathTidyKeysHelpSyn,
+		HelpDescription: pathTidyKeysHelpDesc,
+	}
+}
+
+func (b *Backend) pathTidyKeysUpdate(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	dryRun := d.Get("dry_run").(bool)
+
+	orphans, err := b.tidyAPIKeys(ctx, req.Storage, dryRun)
+	if orphans == nil && err != nil {
+		return nil, err
+	}
+
+	keys := make([]map[string]interface{}, 0, len(orphans))
+	for _, orphan := range orphans {
+		keys = append(keys, map[string]interface{}{
+			"programmatic_api_key_id": orphan.ID,
+			"public_key":              orphan.PublicKey,
+			"description":             orphan.Desc,
+			"organization_id":         orphan.OrganizationID,
+			"connection":              orphan.Connection,
+			"deleted":                 orphan.Deleted,
+		})
+	}
+
+	resp := &logical.Response{
+		Data: map[string]interface{}{
+			"keys":    keys,
+			"dry_run": dryRun,
+		},
+	}
+	if merr, ok := err.(*multierror.Error); ok {
+		for _, err := range merr.Errors {
+			resp.AddWarning(err.Error())
+		}
+	}
+	return resp, nil
+}
+
+// orphanedAPIKey is a programmatic API key created by the backend that is no
+// longer tracked by a lease.
+type orphanedAPIKey struct {
+	mongodbatlas.APIKey
+	OrganizationID string
+	Connection     string
+	Deleted        bool
+}
+
+// tidyAPIKeys finds the programmatic API keys created by the backend that have
+// no active lease, and deletes them unless dryRun is set. Keys that couldn't
+// be deleted are returned along with a multierror describing the failures.
+func (b *Backend) tidyAPIKeys(ctx context.Context, s logical.Storage, dryRun bool) ([]*orphanedAPIKey, error) {
+	b.tidyMutex.Lock()
+	defer b.tidyMutex.Unlock()
+
+	connections, err := connectionNames(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only keys issued by this mount are candidates. Keys issued by other
+	// mounts, or before the descriptions identified the mount, are left
+	// alone.
+	mountID, err := b.getMountID(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	descriptionRegex := mountDescriptionRegex(mountID)
+
+	// The keys are listed before the WAL entries and the issued keys, as a
+	// key being created is tracked by its WAL entry until it is issued.
+	var candidates []*orphanedAPIKey
+	candidateIDs := map[string]bool{}
+	connectionKeys := map[string]bool{}
+	for _, connection := range connections {
+		cfg, err := getConnectionConfig(ctx, s, connection)
+		if err != nil {
+			return nil, err
+		}
+		connectionKeys[cfg.PublicKey] = true
+
+		client, err := b.connectionClient(ctx, s, connection)
+		if err != nil {
+			return nil, err
+		}
+
+		orgIDs, err := tidyOrganizations(ctx, s, client, connection)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, orgID := range orgIDs {
+			keys, err := listAPIKeys(ctx, client, orgID)
+			if err != nil {
+				return nil, errwrap.Wrapf(fmt.Sprintf("error listing programmatic API keys of organization %q: {{err}}", orgID), err)
+			}
+			for _, key := range keys {
+				// Connections to the same organization list the same keys
+				if descriptionRegex.MatchString(key.Desc) && !candidateIDs[key.ID] {
+					candidateIDs[key.ID] = true
+					candidates = append(candidates, &orphanedAPIKey{
+						APIKey:         key,
+						OrganizationID: orgID,
+						Connection:     connection,
+					})
+				}
+			}
+		}
+	}
+
+	pendingDescriptions, protectedIDs, err := pendingAPIKeys(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+
+	// Recorded keys are only revoked by their lease, unless the lease has
+	// expired for good, in which case Vault gave up revoking the key or it
+	// was revoked with force
+	issuedIDs, err := s.List(ctx, issuedAPIKeyPath)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for _, id := range issuedIDs {
+		issued, err := getIssuedAPIKey(ctx, s, id)
+		if err != nil {
+			return nil, err
+		}
+		if issued == nil {
+			continue
+		}
+		if !b.issuedLeaseExpired(issued, now) || issued.OrganizationID == "" {
+			protectedIDs[id] = true
+			continue
+		}
+		// Expired keys are deleted even when their description isn't
+		// matched, as the backend recorded issuing them
+		if !candidateIDs[id] {
+			candidateIDs[id] = true
+			candidates = append(candidates, &orphanedAPIKey{
+				APIKey: mongodbatlas.APIKey{
+					ID:        issued.APIKeyID,
+					PublicKey: issued.PublicKey,
+					Desc:      issued.Description,
+				},
+				OrganizationID: issued.OrganizationID,
+				Connection:     issued.Connection,
+			})
+		}
+	}
+
+	staticIDs, err := staticRoleAPIKeyIDs(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range staticIDs {
+		protectedIDs[id] = true
+	}
+
+	var orphans []*orphanedAPIKey
+	var merr error
+	for _, candidate := range candidates {
+		if protectedIDs[candidate.ID] || pendingDescriptions[candidate.Desc] || connectionKeys[candidate.PublicKey] {
+			continue
+		}
+		orphans = append(orphans, candidate)
+
+		if dryRun {
+			continue
+		}
+		client, err := b.connectionClient(ctx, s, candidate.Connection)
+		if err != nil {
+			merr = multierror.Append(merr, err)
+			continue
+		}
+		if err := deleteAPIKey(ctx, client, candidate.OrganizationID, candidate.ID); err != nil {
+			merr = multierror.Append(merr, errwrap.Wrapf(fmt.Sprintf("error deleting programmatic API key %q: {{err}}", candidate.ID), err))
+			continue
+		}
+		candidate.Deleted = true
+
+		if err := deleteIssuedAPIKey(ctx, s, candidate.ID); err != nil {
+			merr = multierror.Append(merr, errwrap.Wrapf(fmt.Sprintf("error deleting issued programmatic API key %q: {{err}}", candidate.ID), err))
+		}
+	}
+
+	return orphans, merr
+}
+
+// issuedLeaseExpiryGracePeriod is how long tidy waits after the maximum TTL
+// of a lease before deleting its key, which leaves Vault time to retry the
+// revocation.
+const issuedLeaseExpiryGracePeriod = 24 * time.Hour
+
+// issuedLeaseExpired reports whether the lease of an issued key reached its
+// maximum TTL more than the grace period ago. Keys issued before the maximum
+// TTL was recorded are bounded by the maximum lease TTL of the mount.
+func (b *Backend) issuedLeaseExpired(issued *issuedAPIKeyEntry, now time.Time) bool {
+	if issued.IssueTime.IsZero() {
+		return false
+	}
+
+	maxTTL := issued.MaxTTL
+	if maxTTL == 0 {
+		maxTTL = b.System().MaxLeaseTTL()
+	}
+	return now.After(issued.IssueTime.Add(maxTTL + issuedLeaseExpiryGracePeriod))
+}
+
+// tidyOrganizations returns the organizations the backend may have created
+// programmatic API keys in with a connection.
+func tidyOrganizations(ctx context.Context, s logical.Storage, client *mongodbatlas.Client, connection string) ([]string, error) {
+	orgIDs := map[string]bool{}
+	projectIDs := map[string]bool{}
+
+	cfg, err := getConnectionConfig(ctx, s, connection)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.OrganizationID != "" {
+		orgIDs[cfg.OrganizationID] = true
+	}
+
+	roleNames, err := listRoleNames(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range roleNames {
+		entry, err := s.Get(ctx, "roles/"+name)
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			continue
+		}
+		var cred atlasCredentialEntry
+		if err := entry.DecodeJSON(&cred); err != nil {
+			return nil, err
+		}
+		if cred.CredentialType == databaseUser || cred.Connection != connection {
+			continue
+		}
+		switch {
+		case cred.OrganizationID != "":
+			orgIDs[cred.OrganizationID] = true
+		case cred.ProjectID != "":
+			projectIDs[cred.ProjectID] = true
+		}
+	}
+
+	// Keys issued by roles that have since been deleted
+	issuedIDs, err := s.List(ctx, issuedAPIKeyPath)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range issuedIDs {
+		issued, err := getIssuedAPIKey(ctx, s, id)
+		if err != nil {
+			return nil, err
+		}
+		if issued != nil && issued.OrganizationID != "" && issued.Connection == connection {
+			orgIDs[issued.OrganizationID] = true
+		}
+	}
+
+	for projectID := range projectIDs {
+		orgID, err := projectOrganizationID(ctx, client, projectID)
+		if err != nil {
+			return nil, err
+		}
+		orgIDs[orgID] = true
+	}
+
+	var result []string
+	for orgID := range orgIDs {
+		result = append(result, orgID)
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// pendingAPIKeys returns the descriptions of the programmatic API keys being
+// created, and the IDs of the keys being rotated, according to the WAL.
+func pendingAPIKeys(ctx context.Context, s logical.Storage) (map[string]bool, map[string]bool, error) {
+	descriptions := map[string]bool{}
+	ids := map[string]bool{}
+
+	walIDs, err := framework.ListWAL(ctx, s)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, walID := range walIDs {
+		wal, err := framework.GetWAL(ctx, s, walID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if wal == nil {
+			continue
+		}
+
+		switch wal.Kind {
+		case programmaticAPIKey:
+			var entry walEntry
+			if err := mapstructure.Decode(wal.Data, &entry); err != nil {
+				return nil, nil, err
+			}
+			descriptions[entry.UserName] = true
+		case rootRotationWALKind:
+			var entry rootRotationWALEntry
+			if err := mapstructure.Decode(wal.Data, &entry); err != nil {
+				return nil, nil, err
+			}
+			descriptions[entry.Description] = true
+			ids[entry.NewAPIKeyID] = true
+		case staticRotationWALKind:
+			var entry staticRotationWALEntry
+			if err := mapstructure.Decode(wal.Data, &entry); err != nil {
+				return nil, nil, err
+			}
+			descriptions[entry.Description] = true
+			ids[entry.NewAPIKeyID] = true
+		}
+	}
+
+	return descriptions, ids, nil
+}
+
+// staticRoleAPIKeyIDs returns the current and replaced keys of the static
+// roles.
+func staticRoleAPIKeyIDs(ctx context.Context, s logical.Storage) ([]string, error) {
+	names, err := s.List(ctx, staticRolePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, name := range names {
+		entry, err := staticRoleRead(ctx, s, name)
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			continue
+		}
+		ids = append(ids, entry.APIKeyID)
+		for _, retired := range entry.RetiredAPIKeys {
+			ids = append(ids, retired.ID)
+		}
+	}
+	return ids, nil
+}
+
+// tidyIfDue deletes the orphaned programmatic API keys once the configured
+// tidy interval has elapsed since the last periodic tidy.
+func (b *Backend) tidyIfDue(ctx context.Context, s logical.Storage) error {
+	cfg, err := readRootConfig(ctx, s)
+	if err != nil {
+		return err
+	}
+	if cfg == nil || cfg.TidyInterval == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	if now.Before(b.lastTidy.Add(cfg.TidyInterval)) {
+		return nil
+	}
+	b.lastTidy = now
+
+	orphans, err := b.tidyAPIKeys(ctx, s, false)
+	deleted := 0
+	for _, orphan := range orphans {
+		if orphan.Deleted {
+			deleted++
+		}
+	}
+	if deleted > 0 {
+		b.Logger().Info("deleted orphaned programmatic API keys", "count", deleted)
+	}
+	if err != nil {
+		return errwrap.Wrapf("error tidying programmatic API keys: {{err}}", err)
+	}
+	return nil
+}
+
+const pathTidyKeysHelpSyn = `
+Delete the MongoDB Atlas Programmatic API Keys left behind by the backend.
+`
+const pathTidyKeysHelpDesc = `
+This path finds the MongoDB Atlas Programmatic API Keys created by the
+backend that no longer have an active lease, for instance after a failed
+revocation, and deletes them. With "dry_run", the keys are only reported.
+
+Keys recorded as issued are deleted once their lease is past its maximum
+TTL by more than a day, which happens when the lease was revoked with
+force or Vault gave up revoking it. Their record is removed as well.
+
+The organizations of the configured credentials and of the roles are
+searched for keys with a description generated by this mount, which
+includes an identifier of the mount. Keys of the root credentials and of
+static roles are never deleted.
+
+Keys issued by other mounts, or before the descriptions identified the
+mount, are never deleted. Revoke them with "revoke/public-key" or the
+"revoke-all" endpoints instead.
+`
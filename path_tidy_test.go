@@ -0,0 +1,252 @@
+package mongodbatlas
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/mongodb/go-client-mongodb-atlas/mongodbatlas"
+)
+
+func TestBackend_TidyKeys(t *testing.T) {
+	b, storage, atlas := newFakeAtlasBackend(t)
+	defer atlas.Close()
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      "roles/test-programmatic-key",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"organization_id": fakeOrganizationID,
+			"roles":           []string{"ORG_MEMBER"},
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: role creation failed:. resp:%#v err:%v", resp, err)
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "creds/test-programmatic-key",
+		Storage:   storage,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: reading credentials failed:. resp:%#v err:%v", resp, err)
+	}
+	leasedKeyID := resp.Secret.InternalData["programmatic_api_key_id"].(string)
+
+	mountID, err := b.getMountID(context.Background(), storage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !mountDescriptionRegex(mountID).MatchString(atlas.key(leasedKeyID).Desc) {
+		t.Fatalf("expected the description to identify the mount, got %q", atlas.key(leasedKeyID).Desc)
+	}
+
+	// Enough orphans to span several pages, a key not created by Vault, and
+	// keys of another mount and from before the descriptions identified the
+	// mount
+	roles := []mongodbatlas.APIKeyRole{{OrgID: fakeOrganizationID, RoleName: "ORG_MEMBER"}}
+	atlas.Lock()
+	for i := 0; i < listPageSize+10; i++ {
+		atlas.addKey(fakeOrganizationID, fmt.Sprintf("vault-test-programmatic-key-%s-%020d", mountID, i), roles)
+	}
+	manualKey := atlas.addKey(fakeOrganizationID, "vault-admin", roles)
+	otherMountKey := atlas.addKey(fakeOrganizationID, "vault-test-programmatic-key-00000000-aaaaaaaaaaaaaaaaaaaa", roles)
+	legacyKey := atlas.addKey(fakeOrganizationID, "vault-test-programmatic-key-aaaaaaaaaaaaaaaaaaaa", roles)
+	atlas.Unlock()
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "tidy/keys",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"dry_run": true,
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: tidy failed:. resp:%#v err:%v", resp, err)
+	}
+
+	keys := resp.Data["keys"].([]map[string]interface{})
+	if len(keys) != listPageSize+10 {
+		t.Fatalf("expected %d orphaned keys, got %d", listPageSize+10, len(keys))
+	}
+	for _, key := range keys {
+		switch key["programmatic_api_key_id"] {
+		case leasedKeyID, manualKey.ID, otherMountKey.ID, legacyKey.ID:
+			t.Fatalf("bad: unexpected orphaned key %v", key)
+		}
+		if key["deleted"].(bool) {
+			t.Fatal("expected a dry run not to delete keys")
+		}
+	}
+	if atlas.keyCount() != listPageSize+15 {
+		t.Fatalf("expected a dry run not to delete keys, got %d keys", atlas.keyCount())
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "tidy/keys",
+		Storage:   storage,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: tidy failed:. resp:%#v err:%v", resp, err)
+	}
+
+	// The root key, the leased key, the manual key and the keys not issued
+	// by the mount remain
+	if atlas.keyCount() != 5 {
+		t.Fatalf("expected the orphaned keys to be deleted, got %d keys", atlas.keyCount())
+	}
+	if atlas.key(leasedKeyID) == nil || atlas.key(manualKey.ID) == nil || atlas.key(atlas.rootKey) == nil || atlas.key(otherMountKey.ID) == nil || atlas.key(legacyKey.ID) == nil {
+		t.Fatal("expected the keys not orphaned to remain")
+	}
+}
+
+func TestBackend_TidyKeys_RenewTracksKey(t *testing.T) {
+	b, storage, atlas := newFakeAtlasBackend(t)
+	defer atlas.Close()
+
+	mountID, err := b.getMountID(context.Background(), storage)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	atlas.Lock()
+	key := atlas.addKey(fakeOrganizationID, "vault-test-programmatic-key-"+mountID+"-aaaaaaaaaaaaaaaaaaaa", []mongodbatlas.APIKeyRole{
+		{OrgID: fakeOrganizationID, RoleName: "ORG_MEMBER"},
+	})
+	atlas.Unlock()
+
+	// A lease issued before the keys were tracked
+	secret := &logical.Secret{
+		LeaseID: "mongodbatlas/creds/test-programmatic-key/abcd",
+		InternalData: map[string]interface{}{
+			"secret_type":             programmaticAPIKey,
+			"programmatic_api_key_id": key.ID,
+			"organization_id":         fakeOrganizationID,
+			"project_id":              "",
+		},
+	}
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.RenewOperation,
+		Storage:   storage,
+		Secret:    secret,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: renewing credentials failed:. resp:%#v err:%v", resp, err)
+	}
+
+	issued, err := getIssuedAPIKey(context.Background(), storage, key.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if issued == nil || issued.PublicKey != key.PublicKey || issued.LeaseID != secret.LeaseID {
+		t.Fatalf("bad: unexpected issued key %#v", issued)
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "tidy/keys",
+		Storage:   storage,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: tidy failed:. resp:%#v err:%v", resp, err)
+	}
+	if atlas.key(key.ID) == nil {
+		t.Fatal("expected the renewed key not to be deleted")
+	}
+}
+
+func TestBackend_TidyKeys_ExpiredLease(t *testing.T) {
+	b, storage, atlas := newFakeAtlasBackend(t)
+	defer atlas.Close()
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      "roles/test-programmatic-key",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"organization_id": fakeOrganizationID,
+			"roles":           []string{"ORG_MEMBER"},
+			"max_ttl":         "2h",
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: role creation failed:. resp:%#v err:%v", resp, err)
+	}
+
+	var keyIDs []string
+	for i := 0; i < 2; i++ {
+		resp, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "creds/test-programmatic-key",
+			Storage:   storage,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("bad: reading credentials failed:. resp:%#v err:%v", resp, err)
+		}
+		keyIDs = append(keyIDs, resp.Secret.InternalData["programmatic_api_key_id"].(string))
+	}
+
+	// A key issued before the descriptions identified the mount
+	atlas.Lock()
+	legacyKey := atlas.addKey(fakeOrganizationID, "vault-test-programmatic-key-aaaaaaaaaaaaaaaaaaaa", []mongodbatlas.APIKeyRole{
+		{OrgID: fakeOrganizationID, RoleName: "ORG_MEMBER"},
+	})
+	atlas.Unlock()
+	if err := putIssuedAPIKey(context.Background(), storage, &issuedAPIKeyEntry{
+		APIKeyID:       legacyKey.ID,
+		PublicKey:      legacyKey.PublicKey,
+		OrganizationID: fakeOrganizationID,
+		Description:    legacyKey.Desc,
+		Role:           "test-programmatic-key",
+		IssueTime:      time.Now().Add(-b.System().MaxLeaseTTL() - 2*issuedLeaseExpiryGracePeriod).UTC(),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The lease of the first key expired, but the key was never revoked
+	issued, err := getIssuedAPIKey(context.Background(), storage, keyIDs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if issued.MaxTTL != 2*time.Hour {
+		t.Fatalf("expected the max ttl of the lease to be recorded, got %s", issued.MaxTTL)
+	}
+	issued.IssueTime = time.Now().Add(-issued.MaxTTL - issuedLeaseExpiryGracePeriod - time.Minute).UTC()
+	if err := putIssuedAPIKey(context.Background(), storage, issued); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "tidy/keys",
+		Storage:   storage,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: tidy failed:. resp:%#v err:%v", resp, err)
+	}
+
+	if atlas.key(keyIDs[0]) != nil || atlas.key(legacyKey.ID) != nil {
+		t.Fatal("expected the keys with expired leases to be deleted")
+	}
+	if atlas.key(keyIDs[1]) == nil {
+		t.Fatal("expected the key with an active lease to remain")
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ListOperation,
+		Path:      "keys/",
+		Storage:   storage,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: listing keys failed:. resp:%#v err:%v", resp, err)
+	}
+	if keys := resp.Data["keys"].([]string); len(keys) != 1 || keys[0] != keyIDs[1] {
+		t.Fatalf("expected only the key with an active lease to be listed, got %v", keys)
+	}
+}
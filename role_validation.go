@@ -0,0 +1,130 @@
+package mongodbatlas
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/vault/sdk/helper/strutil"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/mongodb/go-client-mongodb-atlas/mongodbatlas"
+)
+
+// orgRoleCatalogue and projectRoleCatalogue are the roles MongoDB Atlas grants
+// to programmatic API keys at each key level.
+var (
+	orgRoleCatalogue = []string{
+		"ORG_OWNER",
+		"ORG_MEMBER",
+		"ORG_GROUP_CREATOR",
+		"ORG_BILLING_ADMIN",
+		"ORG_READ_ONLY",
+	}
+	projectRoleCatalogue = []string{
+		"GROUP_CHARTS_ADMIN",
+		"GROUP_CLUSTER_MANAGER",
+		"GROUP_DATA_ACCESS_ADMIN",
+		"GROUP_DATA_ACCESS_READ_ONLY",
+		"GROUP_DATA_ACCESS_READ_WRITE",
+		"GROUP_OWNER",
+		"GROUP_READ_ONLY",
+	}
+)
+
+// validateRole checks the roles of a role against the role catalogue, and
+// its organization and project against MongoDB Atlas. All the failures are
+// returned in a single response with status 400, whose "errors" lists them by
+// the field they concern.
+func (b *Backend) validateRole(ctx context.Context, req *logical.Request, credentialEntry *atlasCredentialEntry) (*logical.Response, error) {
+	fieldErrors := map[string][]string{}
+	addError := func(field, format string, args ...interface{}) {
+		fieldErrors[field] = append(fieldErrors[field], fmt.Sprintf(format, args...))
+	}
+
+	if credentialEntry.CredentialType == programmaticAPIKey {
+		switch {
+		case isOrgKey(credentialEntry.OrganizationID, credentialEntry.ProjectID):
+			validateRoleLevel(addError, "roles", credentialEntry.Roles, orgProgrammaticAPIKey)
+		case isProjectKey(credentialEntry.OrganizationID, credentialEntry.ProjectID):
+			validateRoleLevel(addError, "roles", credentialEntry.Roles, projectProgrammaticAPIKey)
+		case isAssignedToProject(credentialEntry.OrganizationID, credentialEntry.ProjectID):
+			validateRoleLevel(addError, "roles", credentialEntry.Roles, orgProgrammaticAPIKey)
+			validateRoleLevel(addError, "project_roles", credentialEntry.ProjectRoles, projectProgrammaticAPIKey)
+		}
+	}
+
+	client, err := b.connectionClient(ctx, req.Storage, credentialEntry.Connection)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	if credentialEntry.OrganizationID != "" {
+		res, err := getOrganization(ctx, client, credentialEntry.OrganizationID)
+		switch {
+		case res != nil && res.StatusCode == http.StatusNotFound:
+			addError("organization_id", "organization %q does not exist", credentialEntry.OrganizationID)
+		case err != nil:
+			addError("organization_id", "unable to read organization %q: %s", credentialEntry.OrganizationID, err)
+		}
+	}
+
+	if credentialEntry.ProjectID != "" {
+		project, res, err := client.Projects.GetOneProject(ctx, credentialEntry.ProjectID)
+		switch {
+		case res != nil && res.StatusCode == http.StatusNotFound:
+			addError("project_id", "project %q does not exist", credentialEntry.ProjectID)
+		case err != nil:
+			addError("project_id", "unable to read project %q: %s", credentialEntry.ProjectID, err)
+		case credentialEntry.OrganizationID != "" && project.OrgID != credentialEntry.OrganizationID:
+			addError("project_id", "project %q belongs to organization %q, not %q", credentialEntry.ProjectID, project.OrgID, credentialEntry.OrganizationID)
+		}
+	}
+
+	if len(fieldErrors) == 0 {
+		return nil, nil
+	}
+
+	// Error responses only carry their message, so the failures are
+	// returned with the status code instead
+	var result *multierror.Error
+	for _, field := range []string{"roles", "project_roles", "organization_id", "project_id"} {
+		for _, message := range fieldErrors[field] {
+			result = multierror.Append(result, fmt.Errorf("%s: %s", field, message))
+		}
+	}
+	return logical.RespondWithStatusCode(&logical.Response{
+		Data: map[string]interface{}{
+			"error":  fmt.Sprintf("invalid role: %s", result),
+			"errors": fieldErrors,
+		},
+	}, req, http.StatusBadRequest)
+}
+
+// validateRoleLevel checks that the roles of a field are in the catalogue of
+// the given key level.
+func validateRoleLevel(addError func(field, format string, args ...interface{}), field string, roles []string, level string) {
+	catalogue, other := orgRoleCatalogue, projectRoleCatalogue
+	if level == projectProgrammaticAPIKey {
+		catalogue, other = projectRoleCatalogue, orgRoleCatalogue
+	}
+
+	for _, role := range roles {
+		switch {
+		case strutil.StrListContains(catalogue, role):
+		case strutil.StrListContains(other, role):
+			addError(field, "%q is not valid at the %s level", role, level)
+		default:
+			addError(field, "%q is not a valid %s role, valid roles are %v", role, level, catalogue)
+		}
+	}
+}
+
+// getOrganization reads an organization, which the client has no service for.
+func getOrganization(ctx context.Context, client *mongodbatlas.Client, orgID string) (*mongodbatlas.Response, error) {
+	req, err := client.NewRequest(ctx, http.MethodGet, fmt.Sprintf("orgs/%s", orgID), nil)
+	if err != nil {
+		return nil, err
+	}
+	return client.Do(ctx, req, nil)
+}
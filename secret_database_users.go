@@ -0,0 +1,241 @@
+package mongodbatlas
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/base62"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/mitchellh/mapstructure"
+	"github.com/mongodb/go-client-mongodb-atlas/mongodbatlas"
+)
+
+const defaultDatabaseName = "admin"
+
+func (b *Backend) databaseUsers() *framework.Secret {
+	return &framework.Secret{
+		Type: databaseUser,
+		Fields: map[string]*framework.FieldSchema{
+			"username": {
+				Type:        framework.TypeString,
+				Description: "Database User Username",
+			},
+
+			"password": {
+				Type:        framework.TypeString,
+				Description: "Database User Password",
+			},
+		},
+		Renew:  b.credentialRenew,
+		Revoke: b.databaseUserRevoke,
+	}
+}
+
+func (b *Backend) databaseUserCreate(ctx context.Context, s logical.Storage, displayName string, cred *atlasCredentialEntry) (*logical.Response, error) {
+	username, err := genUsername(displayName)
+	if err != nil {
+		return nil, errwrap.Wrapf("error generating username: {{err}}", err)
+	}
+
+	password, err := base62.Random(32)
+	if err != nil {
+		return nil, errwrap.Wrapf("error generating password: {{err}}", err)
+	}
+
+	roles, err := parseDatabaseRoles(cred.DatabaseRoles, cred.DatabaseName)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := b.connectionClient(ctx, s, cred.Connection)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	walID, err := framework.PutWAL(ctx, s, databaseUser, &databaseUserWALEntry{
+		ProjectID:  cred.ProjectID,
+		Username:   username,
+		Connection: cred.Connection,
+	})
+	if err != nil {
+		return nil, errwrap.Wrapf("error writing WAL entry: {{err}}", err)
+	}
+
+	user := &databaseUserRequest{
+		DatabaseUser: mongodbatlas.DatabaseUser{
+			GroupID:      cred.ProjectID,
+			Username:     username,
+			Password:     password,
+			DatabaseName: defaultDatabaseName,
+			Roles:        roles,
+		},
+	}
+	for _, cluster := range cred.Scopes {
+		user.Scopes = append(user.Scopes, databaseUserScope{
+			Name: cluster,
+			Type: "CLUSTER",
+		})
+	}
+
+	if err := createDatabaseUser(ctx, client, cred.ProjectID, user); err != nil {
+		if walErr := framework.DeleteWAL(ctx, s, walID); walErr != nil {
+			dbUserErr := errwrap.Wrapf("error creating database user: {{err}}", err)
+			return nil, errwrap.Wrap(errwrap.Wrapf("failed to delete WAL entry: {{err}}", walErr), dbUserErr)
+		}
+		return logical.ErrorResponse("Error creating database user: %s", err), err
+	}
+
+	if err := framework.DeleteWAL(ctx, s, walID); err != nil {
+		return nil, errwrap.Wrapf("failed to commit WAL entry: {{err}}", err)
+	}
+
+	resp := b.Secret(databaseUser).Response(map[string]interface{}{
+		"username": username,
+		"password": password,
+	}, map[string]interface{}{
+		"project_id": cred.ProjectID,
+		"username":   username,
+		"connection": cred.Connection,
+		"role":       displayName,
+	})
+
+	defaultLease, maxLease, err := b.getCredentialLease(ctx, s, cred)
+	if err != nil {
+		return nil, err
+	}
+
+	resp.Secret.TTL = defaultLease
+	resp.Secret.MaxTTL = maxLease
+
+	return resp, nil
+}
+
+// databaseUserRequest adds the cluster scopes, which the client doesn't
+// support yet, to a database user.
+type databaseUserRequest struct {
+	mongodbatlas.DatabaseUser
+	Scopes []databaseUserScope `json:"scopes,omitempty"`
+}
+
+type databaseUserScope struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+func createDatabaseUser(ctx context.Context, client *mongodbatlas.Client, projectID string, user *databaseUserRequest) error {
+	req, err := client.NewRequest(ctx, http.MethodPost, fmt.Sprintf("groups/%s/databaseUsers", projectID), user)
+	if err != nil {
+		return err
+	}
+
+	_, err = client.Do(ctx, req, nil)
+	return err
+}
+
+// parseDatabaseRoles parses roles in the form "role@database" or
+// "role@database.collection". Roles without a database are granted on
+// defaultDB, or on the admin database if it is empty.
+func parseDatabaseRoles(databaseRoles []string, defaultDB string) ([]mongodbatlas.Role, error) {
+	if defaultDB == "" {
+		defaultDB = defaultDatabaseName
+	}
+
+	var roles []mongodbatlas.Role
+	for _, databaseRole := range databaseRoles {
+		role := mongodbatlas.Role{
+			DatabaseName: defaultDB,
+		}
+
+		parts := strings.SplitN(databaseRole, "@", 2)
+		role.RoleName = parts[0]
+		if len(parts) == 2 {
+			// Database names can't contain dots, so anything after the
+			// first one is the collection
+			namespace := strings.SplitN(parts[1], ".", 2)
+			role.DatabaseName = namespace[0]
+			if len(namespace) == 2 {
+				role.CollectionName = namespace[1]
+			}
+		}
+
+		if role.RoleName == "" || role.DatabaseName == "" {
+			return nil, fmt.Errorf("invalid database role %q, expected \"role@database\" or \"role@database.collection\"", databaseRole)
+		}
+
+		roles = append(roles, role)
+	}
+
+	return roles, nil
+}
+
+func (b *Backend) databaseUserRevoke(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	usernameRaw, ok := req.Secret.InternalData["username"]
+	if !ok {
+		return nil, errors.New("secret is missing username internal data")
+	}
+
+	username, ok := usernameRaw.(string)
+	if !ok {
+		return nil, errors.New("secret is missing username internal data")
+	}
+
+	projectIDRaw, ok := req.Secret.InternalData["project_id"]
+	if !ok {
+		return nil, errors.New("secret is missing project_id internal data")
+	}
+
+	projectID, ok := projectIDRaw.(string)
+	if !ok {
+		return nil, errors.New("secret is missing project_id internal data")
+	}
+
+	connection, _ := req.Secret.InternalData["connection"].(string)
+
+	// Use the user rollback mechanism to delete this database_user
+	if err := b.deleteDatabaseUser(ctx, req.Storage, &databaseUserWALEntry{
+		ProjectID:  projectID,
+		Username:   username,
+		Connection: connection,
+	}); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+func (b *Backend) databaseUserRollback(ctx context.Context, req *logical.Request, data interface{}) error {
+	var entry databaseUserWALEntry
+	if err := mapstructure.Decode(data, &entry); err != nil {
+		return err
+	}
+
+	return b.deleteDatabaseUser(ctx, req.Storage, &entry)
+}
+
+func (b *Backend) deleteDatabaseUser(ctx context.Context, s logical.Storage, entry *databaseUserWALEntry) error {
+	client, err := b.connectionClient(ctx, s, entry.Connection)
+	if err != nil {
+		return err
+	}
+
+	// if the user is gone, move along
+	res, err := client.DatabaseUsers.Delete(ctx, entry.ProjectID, entry.Username)
+	if err != nil {
+		if res != nil && res.StatusCode == http.StatusNotFound {
+			return nil
+		}
+		return err
+	}
+
+	return nil
+}
+
+type databaseUserWALEntry struct {
+	ProjectID  string
+	Username   string
+	Connection string
+}
@@ -0,0 +1,122 @@
+package mongodbatlas
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-test/deep"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/mongodb/go-client-mongodb-atlas/mongodbatlas"
+)
+
+func TestBackend_DatabaseUsers(t *testing.T) {
+	b, storage, atlas := newFakeAtlasBackend(t)
+	defer atlas.Close()
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      "roles/test-database-user",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"credential_type": databaseUser,
+			"project_id":      fakeProjectID,
+			"database_name":   "app",
+			"database_roles":  []string{"readWrite", "read@reporting.events"},
+			"scopes":          []string{"Cluster0"},
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: role creation failed:. resp:%#v err:%v", resp, err)
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "creds/test-database-user",
+		Storage:   storage,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: reading credentials failed:. resp:%#v err:%v", resp, err)
+	}
+
+	username := resp.Data["username"].(string)
+	user := atlas.user(fakeProjectID, username)
+	if user == nil {
+		t.Fatal("expected the database user to be created")
+	}
+	if user.Password != resp.Data["password"] || user.DatabaseName != "admin" {
+		t.Fatalf("bad: unexpected database user %#v", user)
+	}
+
+	expectedRoles := []mongodbatlas.Role{
+		{RoleName: "readWrite", DatabaseName: "app"},
+		{RoleName: "read", DatabaseName: "reporting", CollectionName: "events"},
+	}
+	if diff := deep.Equal(expectedRoles, user.Roles); diff != nil {
+		t.Fatal(diff)
+	}
+	if len(user.Scopes) != 1 || user.Scopes[0].Name != "Cluster0" || user.Scopes[0].Type != "CLUSTER" {
+		t.Fatalf("bad: unexpected scopes %v", user.Scopes)
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.RevokeOperation,
+		Storage:   storage,
+		Secret:    resp.Secret,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: revoking credentials failed:. resp:%#v err:%v", resp, err)
+	}
+
+	if atlas.user(fakeProjectID, username) != nil {
+		t.Fatal("expected the database user to be deleted")
+	}
+}
+
+func TestBackend_DatabaseUserRoleValidation(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+
+	b := NewBackend(config.System)
+	if err := b.Setup(context.Background(), config); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, data := range map[string]map[string]interface{}{
+		"missing project_id": {
+			"credential_type": databaseUser,
+			"database_roles":  []string{"readWrite@app"},
+		},
+		"missing database_roles": {
+			"credential_type": databaseUser,
+			"project_id":      fakeProjectID,
+		},
+		"invalid database role": {
+			"credential_type": databaseUser,
+			"project_id":      fakeProjectID,
+			"database_roles":  []string{"readWrite@"},
+		},
+		"access list": {
+			"credential_type": databaseUser,
+			"project_id":      fakeProjectID,
+			"database_roles":  []string{"readWrite@app"},
+			"ip_addresses":    []string{"192.168.1.1"},
+		},
+		"unknown credential type": {
+			"credential_type": "database",
+			"project_id":      fakeProjectID,
+			"database_roles":  []string{"readWrite@app"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			resp, err := b.HandleRequest(context.Background(), &logical.Request{
+				Operation: logical.CreateOperation,
+				Path:      "roles/test-database-user",
+				Storage:   config.StorageView,
+				Data:      data,
+			})
+			if err != nil || resp == nil || !resp.IsError() {
+				t.Fatalf("expected error response. resp:%#v err:%v", resp, err)
+			}
+		})
+	}
+}
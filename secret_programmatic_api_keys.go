@@ -4,11 +4,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"net/http"
 	"time"
 
 	"github.com/hashicorp/errwrap"
 	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/parseutil"
 	"github.com/hashicorp/vault/sdk/logical"
 	"github.com/mitchellh/mapstructure"
 	"github.com/mongodb/go-client-mongodb-atlas/mongodbatlas"
@@ -28,23 +28,44 @@ func (b *Backend) programmaticAPIKeys() *framework.Secret {
 				Description: "Programmatic API Key Private Key",
 			},
 		},
-		Renew:  b.programmaticAPIKeysRenew,
+		Renew:  b.credentialRenew,
 		Revoke: b.programmaticAPIKeyRevoke,
 	}
 }
 
-func (b *Backend) programmaticAPIKeyCreate(ctx context.Context, s logical.Storage, displayName string, cred *atlasCredentialEntry) (*logical.Response, error) {
+func (b *Backend) programmaticAPIKeyCreate(ctx context.Context, req *logical.Request, displayName string, cred *atlasCredentialEntry) (*logical.Response, error) {
+	s := req.Storage
 
-	apiKeyDescription, err := genUsername(displayName)
+	mountID, err := b.getMountID(ctx, s)
 	if err != nil {
-		return nil, errwrap.Wrapf("error generating username: {{err}}", err)
+		return nil, err
+	}
+	apiKeyDescription, err := genAPIKeyDescription(displayName, mountID)
+	if err != nil {
+		return nil, errwrap.Wrapf("error generating description: {{err}}", err)
 	}
-	client, err := b.clientMongo(ctx, s)
+	client, err := b.connectionClient(ctx, s, cred.Connection)
 	if err != nil {
 		return logical.ErrorResponse(err.Error()), nil
 	}
+
+	// Project keys are created in the organization of the project, which is
+	// needed to delete them
+	organizationID := cred.OrganizationID
+	if isProjectKey(cred.OrganizationID, cred.ProjectID) {
+		organizationID, err = projectOrganizationID(ctx, client, cred.ProjectID)
+		if err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+	}
+
+	// The description is unique, so the rollback can find the key even if
+	// it was created without its ID being recorded
 	walID, err := framework.PutWAL(ctx, s, programmaticAPIKey, &walEntry{
-		UserName: apiKeyDescription,
+		UserName:       apiKeyDescription,
+		ProjectID:      cred.ProjectID,
+		OrganizationID: organizationID,
+		Connection:     cred.Connection,
 	})
 	if err != nil {
 		return nil, errwrap.Wrapf("error writing WAL entry: {{err}}", err)
@@ -56,12 +77,16 @@ func (b *Backend) programmaticAPIKeyCreate(ctx context.Context, s logical.Storag
 	case isOrgKey(cred.OrganizationID, cred.ProjectID):
 		key, err = createOrgKey(ctx, client, apiKeyDescription, cred)
 	case isProjectKey(cred.OrganizationID, cred.ProjectID):
-		key, err = createProjectAPIKey(ctx, client, apiKeyDescription, cred)
+		key, err = createProjectAPIKey(ctx, client, organizationID, apiKeyDescription, cred)
 	case isAssignedToProject(cred.OrganizationID, cred.ProjectID):
 		key, err = createAndAssignKey(ctx, client, apiKeyDescription, cred)
 	}
 
 	if err != nil {
+		// The WAL entry is kept for the rollback to retry deleting the key
+		if _, ok := err.(*apiKeyCleanupError); ok {
+			return nil, err
+		}
 		if walErr := framework.DeleteWAL(ctx, s, walID); walErr != nil {
 			dbUserErr := errwrap.Wrapf("error creating programmaticAPIKey: {{err}}", err)
 			return nil, errwrap.Wrap(errwrap.Wrapf("failed to delete WAL entry: {{err}}", walErr), dbUserErr)
@@ -73,6 +98,29 @@ func (b *Backend) programmaticAPIKeyCreate(ctx context.Context, s logical.Storag
 		return nil, errors.New("error creating credential")
 	}
 
+	defaultLease, maxLease, err := b.getCredentialLease(ctx, s, cred)
+	if err != nil {
+		return nil, err
+	}
+
+	// The key is tracked before the WAL entry is deleted, so that tidy never
+	// sees it as orphaned
+	if err := putIssuedAPIKey(ctx, s, &issuedAPIKeyEntry{
+		APIKeyID:       key.ID,
+		PublicKey:      key.PublicKey,
+		OrganizationID: organizationID,
+		ProjectID:      cred.ProjectID,
+		Connection:     cred.Connection,
+		Description:    apiKeyDescription,
+		Role:           displayName,
+		IssueTime:      time.Now().UTC(),
+		MaxTTL:         maxLease,
+		RequestID:      req.ID,
+		EntityID:       req.EntityID,
+	}); err != nil {
+		return nil, errwrap.Wrapf("error storing issued programmatic API key: {{err}}", err)
+	}
+
 	if err := framework.DeleteWAL(ctx, s, walID); err != nil {
 		return nil, errwrap.Wrapf("failed to commit WAL entry: {{err}}", err)
 	}
@@ -84,20 +132,11 @@ func (b *Backend) programmaticAPIKeyCreate(ctx context.Context, s logical.Storag
 	}, map[string]interface{}{
 		"programmatic_api_key_id": key.ID,
 		"project_id":              cred.ProjectID,
-		"organization_id":         cred.OrganizationID,
+		"organization_id":         organizationID,
+		"connection":              cred.Connection,
+		"role":                    displayName,
 	})
 
-	defaultLease, maxLease := b.getDefaultAndMaxLease()
-
-	// If defined, credential TTL overrides default lease configuration
-	if cred.TTL > 0 {
-		defaultLease = cred.TTL
-	}
-
-	if cred.MaxTTL > 0 {
-		maxLease = cred.MaxTTL
-	}
-
 	resp.Secret.TTL = defaultLease
 	resp.Secret.MaxTTL = maxLease
 
@@ -115,19 +154,29 @@ func createOrgKey(ctx context.Context, client *mongodbatlas.Client, apiKeyDescri
 	}
 
 	if err := addWhitelistEntry(ctx, client, credentialEntry.OrganizationID, key.ID, credentialEntry); err != nil {
-		return nil, err
+		return nil, cleanupAPIKey(ctx, client, credentialEntry.OrganizationID, key.ID, errwrap.Wrapf("error adding whitelist entries: {{err}}", err))
 	}
 
 	return key, nil
 }
 
-func createProjectAPIKey(ctx context.Context, client *mongodbatlas.Client, apiKeyDescription string, credentialEntry *atlasCredentialEntry) (*mongodbatlas.APIKey, error) {
+// createProjectAPIKey creates a key in a project. Project keys belong to the
+// organization of the project, where their whitelist is managed.
+func createProjectAPIKey(ctx context.Context, client *mongodbatlas.Client, orgID string, apiKeyDescription string, credentialEntry *atlasCredentialEntry) (*mongodbatlas.APIKey, error) {
 	key, _, err := client.ProjectAPIKeys.Create(ctx, credentialEntry.ProjectID,
 		&mongodbatlas.APIKeyInput{
 			Desc:  apiKeyDescription,
 			Roles: credentialEntry.Roles,
 		})
-	return key, err
+	if err != nil {
+		return nil, err
+	}
+
+	if err := addWhitelistEntry(ctx, client, orgID, key.ID, credentialEntry); err != nil {
+		return nil, cleanupAPIKey(ctx, client, orgID, key.ID, errwrap.Wrapf("error adding whitelist entries: {{err}}", err))
+	}
+
+	return key, nil
 }
 
 func createAndAssignKey(ctx context.Context, client *mongodbatlas.Client, apiKeyDescription string, credentialEntry *atlasCredentialEntry) (*mongodbatlas.APIKey, error) {
@@ -139,12 +188,39 @@ func createAndAssignKey(ctx context.Context, client *mongodbatlas.Client, apiKey
 	if _, err := client.ProjectAPIKeys.Assign(ctx, credentialEntry.ProjectID, key.ID, &mongodbatlas.AssignAPIKey{
 		Roles: credentialEntry.ProjectRoles,
 	}); err != nil {
-		return nil, err
+		return nil, cleanupAPIKey(ctx, client, credentialEntry.OrganizationID, key.ID, errwrap.Wrapf("error assigning the key to the project: {{err}}", err))
 	}
 
 	return key, nil
 }
 
+// cleanupAPIKey deletes a key whose setup failed after it was created, so
+// that it isn't left behind with its roles but without its access list or
+// project assignment. It returns the setup error, or an apiKeyCleanupError
+// if the key couldn't be deleted either.
+func cleanupAPIKey(ctx context.Context, client *mongodbatlas.Client, orgID, keyID string, err error) error {
+	if cleanupErr := deleteAPIKey(ctx, client, orgID, keyID); cleanupErr != nil {
+		return &apiKeyCleanupError{
+			APIKeyID:   keyID,
+			Err:        err,
+			CleanupErr: cleanupErr,
+		}
+	}
+	return err
+}
+
+// apiKeyCleanupError is returned when a key whose setup failed couldn't be
+// deleted, in which case it is left for the WAL rollback.
+type apiKeyCleanupError struct {
+	APIKeyID   string
+	Err        error
+	CleanupErr error
+}
+
+func (e *apiKeyCleanupError) Error() string {
+	return fmt.Sprintf("%s; additionally, the incomplete programmatic API key %q could not be deleted and will be deleted by the rollback: %s", e.Err, e.APIKeyID, e.CleanupErr)
+}
+
 func addWhitelistEntry(ctx context.Context, client *mongodbatlas.Client, orgID string, keyID string, cred *atlasCredentialEntry) error {
 	var entries []*mongodbatlas.WhitelistAPIKeysReq
 	for _, cidrBlock := range cred.CIDRBlocks {
@@ -201,16 +277,22 @@ func (b *Backend) programmaticAPIKeyRevoke(ctx context.Context, req *logical.Req
 		}
 	}
 
-	var data = map[string]interface{}{
-		"organization_id":         organizationID,
-		"programmatic_api_key_id": programmaticAPIKeyID,
-		"project_id":              projectID,
-	}
+	// Secrets issued before connections were introduced use the root
+	// configuration
+	connection, _ := req.Secret.InternalData["connection"].(string)
 
-	// Use the user rollback mechanism to delete this database_user
-	if err := b.pathProgrammaticAPIKeyRollback(ctx, req, programmaticAPIKey, data); err != nil {
+	if err := b.deleteProgrammaticAPIKey(ctx, req.Storage, &walEntry{
+		OrganizationID:       organizationID,
+		ProjectID:            projectID,
+		Connection:           connection,
+		ProgrammaticAPIKeyID: programmaticAPIKeyID,
+	}); err != nil {
 		return nil, err
 	}
+
+	if err := deleteIssuedAPIKey(ctx, req.Storage, programmaticAPIKeyID); err != nil {
+		return nil, errwrap.Wrapf("error deleting issued programmatic API key: {{err}}", err)
+	}
 	return nil, nil
 }
 
@@ -221,84 +303,234 @@ func (b *Backend) pathProgrammaticAPIKeyRollback(ctx context.Context, req *logic
 		return err
 	}
 
-	// Get the client
-	client, err := b.clientMongo(ctx, req.Storage)
-	if err != nil {
+	// WAL entries written before the organization was recorded can't be
+	// resolved. Their descriptions don't identify the mount, so tidy/keys
+	// doesn't find the key either, which can only be removed with
+	// revoke/public-key or the revoke-all endpoints.
+	if entry.ProgrammaticAPIKeyID == "" && entry.OrganizationID == "" && entry.ProjectID == "" {
+		b.Logger().Warn("unable to roll back programmatic API key without an organization, revoke it manually if it was created", "description", entry.UserName)
 		return nil
 	}
 
-	switch {
-	case isOrgKey(entry.OrganizationID, entry.ProjectID):
-		// check if the user exists or not
-		_, res, err := client.APIKeys.Get(ctx, entry.OrganizationID, entry.ProgrammaticAPIKeyID)
-		// if the user is gone, move along
+	if entry.ProgrammaticAPIKeyID == "" {
+		client, err := b.connectionClient(ctx, req.Storage, entry.Connection)
 		if err != nil {
-			if res != nil && res.StatusCode == http.StatusNotFound {
-				return nil
-			}
 			return err
 		}
 
-		// now, delete the api key
-		res, err = client.APIKeys.Delete(ctx, entry.OrganizationID, entry.ProgrammaticAPIKeyID)
-		if err != nil {
-			if res != nil && res.StatusCode == http.StatusNotFound {
-				return nil
+		if entry.OrganizationID == "" {
+			if entry.OrganizationID, err = projectOrganizationID(ctx, client, entry.ProjectID); err != nil {
+				return err
 			}
-			return err
 		}
-	case isProjectKey(entry.OrganizationID, entry.ProjectID):
-		// now, delete the user
-		res, err := client.ProjectAPIKeys.Unassign(ctx, entry.ProjectID, entry.ProgrammaticAPIKeyID)
+
+		key, err := findAPIKeyByDescription(ctx, client, entry.OrganizationID, entry.UserName)
 		if err != nil {
-			if res != nil && res.StatusCode == http.StatusNotFound {
-				return nil
-			}
 			return err
 		}
-	case isAssignedToProject(entry.OrganizationID, entry.ProjectID):
-		// check if the user exists or not
-		_, res, err := client.APIKeys.Get(ctx, entry.OrganizationID, entry.ProgrammaticAPIKeyID)
-		// if the user is gone, move along
-		if err != nil {
-			if res != nil && res.StatusCode == http.StatusNotFound {
-				return nil
-			}
-			return err
+
+		// The key was never created
+		if key == nil {
+			return nil
 		}
+		entry.ProgrammaticAPIKeyID = key.ID
+	}
 
-		// now, delete the api key
-		res, err = client.APIKeys.Delete(ctx, entry.OrganizationID, entry.ProgrammaticAPIKeyID)
-		if err != nil {
-			if res != nil && res.StatusCode == http.StatusNotFound {
-				return nil
-			}
+	if err := b.deleteProgrammaticAPIKey(ctx, req.Storage, &entry); err != nil {
+		return err
+	}
+
+	// The key may have been tracked before the WAL entry could be deleted
+	return deleteIssuedAPIKey(ctx, req.Storage, entry.ProgrammaticAPIKeyID)
+}
+
+// deleteProgrammaticAPIKey deletes a key issued by a role. Keys of project
+// roles are org-level keys too, so they are deleted from the organization of
+// the project rather than only unassigned from it. Leases issued before the
+// organization was stored for project keys are resolved through the project.
+func (b *Backend) deleteProgrammaticAPIKey(ctx context.Context, s logical.Storage, entry *walEntry) error {
+	client, err := b.connectionClient(ctx, s, entry.Connection)
+	if err != nil {
+		return err
+	}
+
+	organizationID := entry.OrganizationID
+	if organizationID == "" {
+		if organizationID, err = projectOrganizationID(ctx, client, entry.ProjectID); err != nil {
 			return err
 		}
-
 	}
 
-	return nil
+	return deleteAPIKey(ctx, client, organizationID, entry.ProgrammaticAPIKeyID)
 }
 
-func (b *Backend) programmaticAPIKeysRenew(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+func (b *Backend) credentialRenew(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
 	// Get the lease (if any)
+	defaultLease, maxLease, err := b.getDefaultAndMaxLease(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	// Secrets issued before the role name was stored only honor the
+	// lease configuration
+	if roleNameRaw, ok := req.Secret.InternalData["role"]; ok {
+		roleName, ok := roleNameRaw.(string)
+		if !ok {
+			return nil, fmt.Errorf("secret has an invalid role internal data")
+		}
 
-	defaultLease, maxLease := b.getDefaultAndMaxLease()
+		cred, err := b.credentialRead(ctx, req.Storage, roleName)
+		if err != nil {
+			return nil, errwrap.Wrapf("error retrieving role: {{err}}", err)
+		}
+		if cred == nil {
+			return nil, fmt.Errorf("role %q no longer exists", roleName)
+		}
+
+		defaultLease, maxLease, err = b.getCredentialLease(ctx, req.Storage, cred)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// The ttl requested when the credential was issued replaces the default
+	// of the role, and bounds the increment requested by the renewal
+	increment := req.Secret.Increment
+	if ttlRaw, ok := req.Secret.InternalData["ttl"]; ok {
+		requestedTTL, err := parseutil.ParseDurationSecond(ttlRaw)
+		if err != nil {
+			return nil, errwrap.Wrapf("secret has an invalid ttl internal data: {{err}}", err)
+		}
+		defaultLease = requestedTTL
+		if increment == 0 || increment > requestedTTL {
+			increment = requestedTTL
+		}
+	}
+
+	if err := b.trackRenewedAPIKey(ctx, req); err != nil {
+		return nil, err
+	}
+
+	ttl, warnings, err := framework.CalculateTTL(b.system, increment, defaultLease, 0, maxLease, 0, req.Secret.IssueTime)
+	if err != nil {
+		return nil, err
+	}
 
 	resp := &logical.Response{Secret: req.Secret}
-	resp.Secret.TTL = defaultLease
+	resp.Secret.TTL = ttl
 	resp.Secret.MaxTTL = maxLease
+	for _, warning := range warnings {
+		resp.AddWarning(warning)
+	}
 	return resp, nil
 }
 
-func (b *Backend) getDefaultAndMaxLease() (time.Duration, time.Duration) {
+// trackRenewedAPIKey tracks the Programmatic API Keys issued before the
+// backend kept track of them, so that tidy doesn't delete them, and records
+// the lease ID of the tracked keys once it is known.
+func (b *Backend) trackRenewedAPIKey(ctx context.Context, req *logical.Request) error {
+	if req.Secret.InternalData["secret_type"] != programmaticAPIKey {
+		return nil
+	}
+
+	keyID, _ := req.Secret.InternalData["programmatic_api_key_id"].(string)
+	if keyID == "" {
+		return nil
+	}
+
+	issued, err := getIssuedAPIKey(ctx, req.Storage, keyID)
+	if err != nil {
+		return err
+	}
+	switch {
+	case issued == nil:
+		issued = &issuedAPIKeyEntry{
+			APIKeyID:  keyID,
+			IssueTime: req.Secret.IssueTime.UTC(),
+			MaxTTL:    req.Secret.MaxTTL,
+		}
+		issued.OrganizationID, _ = req.Secret.InternalData["organization_id"].(string)
+		issued.ProjectID, _ = req.Secret.InternalData["project_id"].(string)
+		issued.Role, _ = req.Secret.InternalData["role"].(string)
+		issued.Connection, _ = req.Secret.InternalData["connection"].(string)
+		b.describeIssuedAPIKey(ctx, req.Storage, issued)
+	case issued.LeaseID != "" || req.Secret.LeaseID == "":
+		return nil
+	}
+	issued.LeaseID = req.Secret.LeaseID
+
+	if err := putIssuedAPIKey(ctx, req.Storage, issued); err != nil {
+		return errwrap.Wrapf("error storing issued programmatic API key: {{err}}", err)
+	}
+	return nil
+}
+
+// describeIssuedAPIKey fills in the public key and description of a key
+// issued before they were recorded. Failures are only logged, as they
+// shouldn't prevent the lease from being renewed.
+func (b *Backend) describeIssuedAPIKey(ctx context.Context, s logical.Storage, issued *issuedAPIKeyEntry) {
+	client, err := b.connectionClient(ctx, s, issued.Connection)
+	if err == nil && issued.OrganizationID == "" {
+		issued.OrganizationID, err = projectOrganizationID(ctx, client, issued.ProjectID)
+	}
+	if err != nil {
+		b.Logger().Warn("error describing issued programmatic API key", "programmatic_api_key_id", issued.APIKeyID, "error", err)
+		return
+	}
+
+	key, _, err := client.APIKeys.Get(ctx, issued.OrganizationID, issued.APIKeyID)
+	if err != nil {
+		b.Logger().Warn("error describing issued programmatic API key", "programmatic_api_key_id", issued.APIKeyID, "error", err)
+		return
+	}
+	issued.PublicKey = key.PublicKey
+	issued.Description = key.Desc
+}
+
+// getCredentialLease returns the lease of a credential, where the role TTLs
+// take precedence over the lease configuration and the system/mount defaults.
+func (b *Backend) getCredentialLease(ctx context.Context, s logical.Storage, cred *atlasCredentialEntry) (time.Duration, time.Duration, error) {
+	defaultLease, maxLease, err := b.getDefaultAndMaxLease(ctx, s)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// If defined, credential TTL overrides default lease configuration
+	if cred.TTL > 0 {
+		defaultLease = cred.TTL
+	}
+
+	if cred.MaxTTL > 0 {
+		maxLease = cred.MaxTTL
+	}
+
+	return defaultLease, maxLease, nil
+}
+
+func (b *Backend) getDefaultAndMaxLease(ctx context.Context, s logical.Storage) (time.Duration, time.Duration, error) {
 	maxLease := b.system.MaxLeaseTTL()
 	defaultLease := b.system.DefaultLeaseTTL()
 
 	if defaultLease > maxLease {
 		maxLease = defaultLease
 	}
-	return defaultLease, maxLease
 
+	// If defined, the lease configuration overrides the system/mount defaults
+	leaseConfig, err := getLeaseConfig(ctx, s)
+	if err != nil {
+		return 0, 0, err
+	}
+	if leaseConfig != nil {
+		if leaseConfig.TTL > 0 {
+			defaultLease = leaseConfig.TTL
+		}
+		if leaseConfig.MaxTTL > 0 {
+			maxLease = leaseConfig.MaxTTL
+		}
+		if defaultLease > maxLease {
+			defaultLease = maxLease
+		}
+	}
+
+	return defaultLease, maxLease, nil
 }
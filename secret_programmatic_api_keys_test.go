@@ -0,0 +1,320 @@
+package mongodbatlas
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func TestBackend_ProgrammaticAPIKeysRenew(t *testing.T) {
+	var resp *logical.Response
+	var err error
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+	config.System = logical.TestSystemView()
+
+	b := NewBackend(config.System)
+	if err := b.Setup(context.Background(), config); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      "roles/test-programmatic-key",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"organization_id": "aspergues",
+			"roles":           []string{"ORG_MEMBER"},
+			"ttl":             "20s",
+			"max_ttl":         "60s",
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: role creation failed:. resp:%#v err:%v", resp, err)
+	}
+
+	secret := &logical.Secret{
+		LeaseOptions: logical.LeaseOptions{
+			IssueTime: time.Now().Add(-50 * time.Second),
+		},
+		InternalData: map[string]interface{}{
+			"secret_type":             programmaticAPIKey,
+			"programmatic_api_key_id": "id",
+			"organization_id":         "aspergues",
+			"role":                    "test-programmatic-key",
+		},
+	}
+
+	// The renewed lease is capped by the role max_ttl
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.RenewOperation,
+		Storage:   config.StorageView,
+		Secret:    secret,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: renew failed:. resp:%#v err:%v", resp, err)
+	}
+	if resp.Secret.TTL > 10*time.Second {
+		t.Fatalf("expected ttl to be capped by the role max_ttl, got %s", resp.Secret.TTL)
+	}
+	if resp.Secret.MaxTTL != 60*time.Second {
+		t.Fatalf("expected max_ttl of 60s, got %s", resp.Secret.MaxTTL)
+	}
+
+	// Renewing a secret whose role has been deleted fails
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.DeleteOperation,
+		Path:      "roles/test-programmatic-key",
+		Storage:   config.StorageView,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: role deletion failed:. resp:%#v err:%v", resp, err)
+	}
+
+	_, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.RenewOperation,
+		Storage:   config.StorageView,
+		Secret:    secret,
+	})
+	if err == nil {
+		t.Fatal("expected an error renewing a secret of a deleted role")
+	}
+}
+
+func TestBackend_ProgrammaticAPIKeysRevoke(t *testing.T) {
+	b, storage, atlas := newFakeAtlasBackend(t)
+	defer atlas.Close()
+
+	for name, data := range map[string]map[string]interface{}{
+		"org-key": {
+			"organization_id": fakeOrganizationID,
+			"roles":           []string{"ORG_MEMBER"},
+		},
+		"project-key": {
+			"project_id": fakeProjectID,
+			"roles":      []string{"GROUP_READ_ONLY"},
+		},
+		"assigned-key": {
+			"organization_id": fakeOrganizationID,
+			"project_id":      fakeProjectID,
+			"roles":           []string{"ORG_MEMBER"},
+			"project_roles":   []string{"GROUP_READ_ONLY"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			resp, err := b.HandleRequest(context.Background(), &logical.Request{
+				Operation: logical.CreateOperation,
+				Path:      "roles/" + name,
+				Storage:   storage,
+				Data:      data,
+			})
+			if err != nil || (resp != nil && resp.IsError()) {
+				t.Fatalf("bad: role creation failed:. resp:%#v err:%v", resp, err)
+			}
+
+			resp, err = b.HandleRequest(context.Background(), &logical.Request{
+				Operation: logical.ReadOperation,
+				Path:      "creds/" + name,
+				Storage:   storage,
+			})
+			if err != nil || (resp != nil && resp.IsError()) {
+				t.Fatalf("bad: reading credentials failed:. resp:%#v err:%v", resp, err)
+			}
+
+			keyID := resp.Secret.InternalData["programmatic_api_key_id"].(string)
+			if atlas.key(keyID) == nil {
+				t.Fatal("expected the programmatic API key to be created")
+			}
+
+			resp, err = b.HandleRequest(context.Background(), &logical.Request{
+				Operation: logical.RevokeOperation,
+				Storage:   storage,
+				Secret:    resp.Secret,
+			})
+			if err != nil || (resp != nil && resp.IsError()) {
+				t.Fatalf("bad: revoking credentials failed:. resp:%#v err:%v", resp, err)
+			}
+
+			if atlas.key(keyID) != nil {
+				t.Fatal("expected the programmatic API key to be deleted")
+			}
+		})
+	}
+}
+
+func TestBackend_ProgrammaticAPIKeysRevoke_ProjectWithoutOrganization(t *testing.T) {
+	b, storage, atlas := newFakeAtlasBackend(t)
+	defer atlas.Close()
+
+	atlas.Lock()
+	key := atlas.addKey(fakeOrganizationID, "vault-test-programmatic-key-aaaaaaaaaaaaaaaaaaaa", nil)
+	atlas.Unlock()
+
+	// Leases of project keys used to only store the project
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.RevokeOperation,
+		Storage:   storage,
+		Secret: &logical.Secret{
+			InternalData: map[string]interface{}{
+				"secret_type":             programmaticAPIKey,
+				"programmatic_api_key_id": key.ID,
+				"organization_id":         "",
+				"project_id":              fakeProjectID,
+			},
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: revoking credentials failed:. resp:%#v err:%v", resp, err)
+	}
+
+	if atlas.key(key.ID) != nil {
+		t.Fatal("expected the programmatic API key to be deleted from the organization of the project")
+	}
+}
+
+func TestBackend_ProgrammaticAPIKeysRollback(t *testing.T) {
+	b, storage, atlas := newFakeAtlasBackend(t)
+	defer atlas.Close()
+
+	atlas.Lock()
+	orgKey := atlas.addKey(fakeOrganizationID, "vault-org-key-aaaaaaaaaaaaaaaaaaaa", nil)
+	projectKey := atlas.addKey(fakeOrganizationID, "vault-project-key-aaaaaaaaaaaaaaaaaaaa", nil)
+	atlas.Unlock()
+
+	// Keys created before their ID could be recorded, and a key that was
+	// never created
+	for _, entry := range []*walEntry{
+		{UserName: orgKey.Desc, OrganizationID: fakeOrganizationID},
+		{UserName: projectKey.Desc, ProjectID: fakeProjectID},
+		{UserName: "vault-missing-key-aaaaaaaaaaaaaaaaaaaa", OrganizationID: fakeOrganizationID},
+	} {
+		if _, err := framework.PutWAL(context.Background(), storage, programmaticAPIKey, entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.RollbackOperation,
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"immediate": true,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if atlas.key(orgKey.ID) != nil || atlas.key(projectKey.ID) != nil {
+		t.Fatal("expected the partially created keys to be deleted")
+	}
+
+	wals, err := framework.ListWAL(context.Background(), storage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(wals) != 0 {
+		t.Fatalf("expected no WAL entries, got %d", len(wals))
+	}
+}
+
+func TestBackend_ProgrammaticAPIKeysCreate_Cleanup(t *testing.T) {
+	for name, tc := range map[string]struct {
+		data     map[string]interface{}
+		resource string
+		method   string
+	}{
+		"access-list": {
+			data: map[string]interface{}{
+				"organization_id": fakeOrganizationID,
+				"roles":           []string{"ORG_MEMBER"},
+				"ip_addresses":    []string{"192.168.1.1"},
+			},
+			method:   http.MethodPost,
+			resource: "access list",
+		},
+		"project-access-list": {
+			data: map[string]interface{}{
+				"project_id":   fakeProjectID,
+				"roles":        []string{"GROUP_READ_ONLY"},
+				"ip_addresses": []string{"192.168.1.1"},
+			},
+			method:   http.MethodPost,
+			resource: "access list",
+		},
+		"project-assignment": {
+			data: map[string]interface{}{
+				"organization_id": fakeOrganizationID,
+				"project_id":      fakeProjectID,
+				"roles":           []string{"ORG_MEMBER"},
+				"project_roles":   []string{"GROUP_READ_ONLY"},
+			},
+			method:   http.MethodPatch,
+			resource: "project key",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			b, storage, atlas := newFakeAtlasBackend(t)
+			defer atlas.Close()
+
+			resp, err := b.HandleRequest(context.Background(), &logical.Request{
+				Operation: logical.CreateOperation,
+				Path:      "roles/" + name,
+				Storage:   storage,
+				Data:      tc.data,
+			})
+			if err != nil || (resp != nil && resp.IsError()) {
+				t.Fatalf("bad: role creation failed:. resp:%#v err:%v", resp, err)
+			}
+
+			keyCount := atlas.keyCount()
+			atlas.fail(tc.method, tc.resource, http.StatusInternalServerError)
+
+			_, err = b.HandleRequest(context.Background(), &logical.Request{
+				Operation: logical.ReadOperation,
+				Path:      "creds/" + name,
+				Storage:   storage,
+			})
+			if err == nil {
+				t.Fatal("expected reading credentials to fail")
+			}
+			if atlas.keyCount() != keyCount {
+				t.Fatalf("expected the new key to be deleted, got %d keys", atlas.keyCount())
+			}
+
+			// The key can't be deleted either, so it is left for the rollback
+			atlas.fail(http.MethodDelete, "key", http.StatusInternalServerError)
+
+			_, err = b.HandleRequest(context.Background(), &logical.Request{
+				Operation: logical.ReadOperation,
+				Path:      "creds/" + name,
+				Storage:   storage,
+			})
+			if _, ok := err.(*apiKeyCleanupError); !ok {
+				t.Fatalf("expected a cleanup error, got %v", err)
+			}
+			if atlas.keyCount() != keyCount+1 {
+				t.Fatalf("expected the new key to remain, got %d keys", atlas.keyCount())
+			}
+
+			atlas.recover(http.MethodDelete, "key")
+
+			_, err = b.HandleRequest(context.Background(), &logical.Request{
+				Operation: logical.RollbackOperation,
+				Storage:   storage,
+				Data: map[string]interface{}{
+					"immediate": true,
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if atlas.keyCount() != keyCount {
+				t.Fatalf("expected the rollback to delete the new key, got %d keys", atlas.keyCount())
+			}
+		})
+	}
+}
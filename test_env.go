@@ -35,8 +35,11 @@ func (e *testEnv) AddConfig(t *testing.T) {
 	if err != nil || (resp != nil && resp.IsError()) {
 		t.Fatalf("bad: resp: %#v\nerr:%v", resp, err)
 	}
-	if resp != nil {
-		t.Fatal("expected nil response to represent a 204")
+	if resp == nil {
+		t.Fatal("expected a response")
+	}
+	if e.OrganizationID != "" && resp.Data["organization_id"] != e.OrganizationID {
+		t.Fatalf("expected organization %q, got %q", e.OrganizationID, resp.Data["organization_id"])
 	}
 }
 
@@ -62,7 +65,7 @@ func (e *testEnv) AddLeaseConfig(t *testing.T) {
 func (e *testEnv) AddProgrammaticAPIKeyRole(t *testing.T) {
 	roles := []string{"ORG_MEMBER"}
 	req := &logical.Request{
-		Operation: logical.UpdateOperation,
+		Operation: logical.CreateOperation,
 		Path:      "roles/test-programmatic-key",
 		Storage:   e.Storage,
 		Data: map[string]interface{}{
@@ -80,7 +83,7 @@ func (e *testEnv) AddProgrammaticAPIKeyRoleWithProjectIDAndOrgID(t *testing.T) {
 	roles := []string{"ORG_MEMBER"}
 	projectRoles := []string{"GROUP_READ_ONLY"}
 	req := &logical.Request{
-		Operation: logical.UpdateOperation,
+		Operation: logical.CreateOperation,
 		Path:      "roles/test-programmatic-key",
 		Storage:   e.Storage,
 		Data: map[string]interface{}{
@@ -99,7 +102,7 @@ func (e *testEnv) AddProgrammaticAPIKeyRoleWithProjectIDAndOrgID(t *testing.T) {
 func (e *testEnv) AddProgrammaticAPIKeyRoleWithTTL(t *testing.T) {
 	roles := []string{"ORG_MEMBER"}
 	req := &logical.Request{
-		Operation: logical.UpdateOperation,
+		Operation: logical.CreateOperation,
 		Path:      "roles/test-programmatic-key",
 		Storage:   e.Storage,
 		Data: map[string]interface{}{
@@ -119,7 +122,7 @@ func (e *testEnv) AddProgrammaticAPIKeyRoleWithIP(t *testing.T) {
 	roles := []string{"ORG_MEMBER"}
 	ips := []string{"192.168.1.1", "192.168.1.2"}
 	req := &logical.Request{
-		Operation: logical.UpdateOperation,
+		Operation: logical.CreateOperation,
 		Path:      "roles/test-programmatic-key",
 		Storage:   e.Storage,
 		Data: map[string]interface{}{
@@ -138,7 +141,7 @@ func (e *testEnv) AddProgrammaticAPIKeyRoleProjectWithIP(t *testing.T) {
 	roles := []string{"ORG_MEMBER"}
 	ips := []string{"192.168.1.1", "192.168.1.2"}
 	req := &logical.Request{
-		Operation: logical.UpdateOperation,
+		Operation: logical.CreateOperation,
 		Path:      "roles/test-programmatic-key",
 		Storage:   e.Storage,
 		Data: map[string]interface{}{
@@ -157,7 +160,7 @@ func (e *testEnv) AddProgrammaticAPIKeyRoleWithCIDR(t *testing.T) {
 	roles := []string{"ORG_MEMBER"}
 	cidrBlocks := []string{"179.154.224.2/32"}
 	req := &logical.Request{
-		Operation: logical.UpdateOperation,
+		Operation: logical.CreateOperation,
 		Path:      "roles/test-programmatic-key",
 		Storage:   e.Storage,
 		Data: map[string]interface{}{
@@ -177,7 +180,7 @@ func (e *testEnv) AddProgrammaticAPIKeyRoleWithCIDRAndIP(t *testing.T) {
 	cidrBlocks := []string{"179.154.224.2/32"}
 	ips := []string{"192.168.1.1", "192.168.1.2"}
 	req := &logical.Request{
-		Operation: logical.UpdateOperation,
+		Operation: logical.CreateOperation,
 		Path:      "roles/test-programmatic-key",
 		Storage:   e.Storage,
 		Data: map[string]interface{}{
@@ -196,7 +199,7 @@ func (e *testEnv) AddProgrammaticAPIKeyRoleWithCIDRAndIP(t *testing.T) {
 func (e *testEnv) AddProgrammaticAPIKeyRoleWithProjectID(t *testing.T) {
 	roles := []string{"ORG_MEMBER"}
 	req := &logical.Request{
-		Operation: logical.UpdateOperation,
+		Operation: logical.CreateOperation,
 		Path:      "roles/test-programmatic-key",
 		Storage:   e.Storage,
 		Data: map[string]interface{}{